@@ -1,9 +1,32 @@
 package events
 
+import "fmt"
+
 // defines all shared event messages
 
 // Indicates a message was sent to the serial port.
-type SerialTxMsg string
+// Data followed by LineEnding are the exact bytes written to the port.
+type SerialTxMsg struct {
+	Data       string
+	LineEnding string
+	Tag        string // tag of the send message
+}
+
+// Requests to send a line of another program, e.g. of a share client, to the
+// serial port. The configured line ending is appended.
+type ForwardMsg struct {
+	Data   string
+	Source string
+}
+
+// Indicates data of another program was sent to the serial port, e.g. data
+// written to the pty mirror. Source names where the data came from. Data
+// followed by LineEnding are the exact bytes written to the port.
+type ForwardedTxMsg struct {
+	Data       string
+	LineEnding string
+	Source     string
+}
 
 // Indicates a message is typed into the input field to filter the command history.
 type PartialTxMsg string
@@ -17,15 +40,72 @@ type InputSuggestion string
 // Indicates data was received from the serial port.
 type SerialRxMsgReceived string
 
+// Indicates unterminated data was received from the serial port and no more
+// data arrived for the idle time. The data is sent again as part of the next
+// SerialRxMsgReceived, once the message is complete.
+type SerialRxPartialMsg string
+
+// Indicates a part of a received line that exceeds the maximum line length.
+// The following rx messages continue the line, up to the next SerialRxMsgReceived.
+type SerialRxSplitMsg string
+
 // Indicates a command from the command history was selected.
-type HistCmdSelected string
+// Data is empty if the selection was cleared.
+type HistCmdSelected struct {
+	Data string
+	Mode InputMode
+}
 
 // Indicates that a messages should be transmitted
 type SendMsg struct {
 	Data        string
+	Mode        InputMode
 	FromCmdHist bool
+	// sent by a macro or script, not added to the command history
+	Automated bool
+	// returned in the SerialTxMsg or the send error, so a sender finds the
+	// result of its own message
+	Tag string
 } // TODO find better naming
 
+// InputMode defines how typed data is converted into the bytes sent to the port.
+type InputMode int
+
+const (
+	TextMode   InputMode = iota // send the text followed by the line ending
+	EscapeMode                  // send the text with escapes like \r or \x1b resolved, no line ending
+	HexMode                     // send hex bytes like "01 0A FF", no line ending
+)
+
+// Name of the input mode as used in the command history file.
+func (mode InputMode) String() string {
+	switch mode {
+	case EscapeMode:
+		return "esc"
+	case HexMode:
+		return "hex"
+	default:
+		return "text"
+	}
+}
+
+// ParseInputMode returns the input mode with the given name, an empty name
+// selects the text mode.
+func ParseInputMode(name string) (InputMode, error) {
+	if name == "" {
+		return TextMode, nil
+	}
+	for _, mode := range []InputMode{TextMode, EscapeMode, HexMode} {
+		if name == mode.String() {
+			return mode, nil
+		}
+	}
+	return TextMode, fmt.Errorf("invalid mode %q, use text, esc or hex", name)
+}
+
+// Indicates the input mode was changed.
+type InputModeMsg InputMode
+
 // Indicates that an error occured
 type ErrMsg error
 
@@ -3,15 +3,18 @@ module github.com/mahlburgc/teaterm
 go 1.25.4
 
 require (
+	github.com/BurntSushi/toml v1.5.0
 	github.com/acarl005/stripansi v0.0.0-20180116102854-5a71ef0e047d
 	github.com/charmbracelet/bubbles v0.21.0
 	github.com/charmbracelet/bubbletea v1.3.10
 	github.com/charmbracelet/lipgloss v1.1.0
 	github.com/icza/gox v0.2.2
 	github.com/lrstanley/bubblezone v1.0.0
+	github.com/mattn/go-isatty v0.0.20
 	github.com/rmhubbert/bubbletea-overlay v0.6.3
 	github.com/sahilm/fuzzy v0.1.1
 	go.bug.st/serial v1.6.4
+	golang.org/x/sys v0.38.0
 )
 
 require (
@@ -27,7 +30,6 @@ require (
 	github.com/creack/goselect v0.1.2 // indirect
 	github.com/erikgeiser/coninput v0.0.0-20211004153227-1c3628e74d0f // indirect
 	github.com/lucasb-eyer/go-colorful v1.3.0 // indirect
-	github.com/mattn/go-isatty v0.0.20 // indirect
 	github.com/mattn/go-localereader v0.0.1 // indirect
 	github.com/mattn/go-runewidth v0.0.19 // indirect
 	github.com/muesli/ansi v0.0.0-20230316100256-276c6243b2f6 // indirect
@@ -35,6 +37,5 @@ require (
 	github.com/muesli/termenv v0.16.0 // indirect
 	github.com/rivo/uniseg v0.4.7 // indirect
 	github.com/xo/terminfo v0.0.0-20220910002029-abceb7e1c41e // indirect
-	golang.org/x/sys v0.38.0 // indirect
 	golang.org/x/text v0.31.0 // indirect
 )
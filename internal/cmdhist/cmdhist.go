@@ -34,6 +34,42 @@ type Model struct {
 	// when it is closed, navigation recalls commands directly into the input
 	// shell-style. Kept in sync via SetPopupOpen.
 	popupOpen bool
+	// inputMode is the current mode of the input, only commands sent in
+	// this mode are suggested.
+	inputMode events.InputMode
+	// global holds the commands taken from the global pool of all profiles
+	// and ports. They are shown dimmed and are not stored in the own history.
+	global map[string]bool
+}
+
+// Commands are stored together with the input mode they were sent in.
+// Commands not sent in text mode are prefixed with the mode name and a tab,
+// e.g. "hex\t01 0A FF". This is also the format of the command history file.
+func encodeCmd(data string, mode events.InputMode) string {
+	if mode == events.TextMode {
+		return data
+	}
+	return mode.String() + "\t" + data
+}
+
+func decodeCmd(cmd string) (string, events.InputMode) {
+	name, data, found := strings.Cut(cmd, "\t")
+	if found {
+		for _, mode := range []events.InputMode{events.EscapeMode, events.HexMode} {
+			if name == mode.String() {
+				return data, mode
+			}
+		}
+	}
+	return cmd, events.TextMode
+}
+
+// Tag shown in front of commands that were not sent in text mode.
+func modeTag(mode events.InputMode) string {
+	if mode == events.TextMode {
+		return ""
+	}
+	return "[" + mode.String() + "] "
 }
 
 // New creates a new model with default settings.
@@ -65,6 +101,28 @@ func New(cmdHist []string) (m Model) {
 	return m
 }
 
+// SetGlobalHistory adds the commands of the global pool, that are not in
+// the own history, in front of the own commands.
+func (m *Model) SetGlobalHistory(globalHist []string) {
+	own := make(map[string]bool, len(m.cmdHist))
+	for _, cmd := range m.cmdHist {
+		own[cmd] = true
+	}
+
+	m.global = make(map[string]bool)
+	var cmdHist []string
+	for _, cmd := range globalHist {
+		if cmd != "" && !own[cmd] && !m.global[cmd] {
+			m.global[cmd] = true
+			cmdHist = append(cmdHist, cmd)
+		}
+	}
+	m.cmdHist = append(cmdHist, m.cmdHist...)
+	m.cmdHistFiltered = m.cmdHist
+	m.cmdHistMatchIdx = make([][]int, len(m.cmdHist))
+	m.cmdHistIndex = len(m.cmdHist)
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 
@@ -83,6 +141,10 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.active = false
 		}
 		return m, cmd
+
+	case events.InputModeMsg:
+		m.inputMode = events.InputMode(msg)
+		return m, nil
 	}
 
 	// do not handle any other events during inactive state
@@ -94,8 +156,8 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	switch msg := msg.(type) {
 
 	case events.SendMsg:
-		if !msg.FromCmdHist {
-			m.AddCmd(msg.Data)
+		if !msg.FromCmdHist && !msg.Automated {
+			m.AddCmd(encodeCmd(msg.Data, msg.Mode))
 		}
 
 	case events.PartialTxMsg:
@@ -178,11 +240,17 @@ func (m *Model) runFuzzySearch(msg string) {
 		m.cmdHistFiltered = m.cmdHist
 		m.cmdHistMatchIdx = make([][]int, len(m.cmdHist))
 	} else {
-		matches := fuzzy.FindNoSort(msg, m.cmdHist)
+		// search the command data only, not the mode
+		data := make([]string, len(m.cmdHist))
+		for i, cmd := range m.cmdHist {
+			data[i], _ = decodeCmd(cmd)
+		}
+
+		matches := fuzzy.FindNoSort(msg, data)
 		m.cmdHistFiltered = make([]string, len(matches))
 		m.cmdHistMatchIdx = make([][]int, len(matches))
 		for i, match := range matches {
-			m.cmdHistFiltered[i] = match.Str
+			m.cmdHistFiltered[i] = m.cmdHist[match.Index]
 			m.cmdHistMatchIdx[i] = match.MatchedIndexes
 		}
 	}
@@ -198,8 +266,8 @@ func (m *Model) findCmd(msg string) tea.Cmd {
 
 	// Iterate backwards to find the most recent match
 	for i := len(m.cmdHist) - 1; i >= 0; i-- {
-		cmd := m.cmdHist[i]
-		if strings.HasPrefix(cmd, msg) {
+		cmd, mode := decodeCmd(m.cmdHist[i])
+		if mode == m.inputMode && strings.HasPrefix(cmd, msg) {
 			// Found a match
 			return func() tea.Msg {
 				return events.InputSuggestion(cmd)
@@ -212,16 +280,18 @@ func (m *Model) findCmd(msg string) tea.Cmd {
 // Returns a Tea command that mirrors the given cmd into the input
 // (events.HistCmdSelected). Used when the selection is confirmed with Enter.
 func SendCmdSelectedMsg(cmd string) tea.Cmd {
+	data, mode := decodeCmd(cmd)
 	return func() tea.Msg {
-		return events.HistCmdSelected(cmd)
+		return events.HistCmdSelected{Data: data, Mode: mode}
 	}
 }
 
 // Returns a Tea command that directly transmits the given cmd
 // (events.SendMsg). Used when a command is clicked with the mouse.
 func SendCmdExecutedMsg(cmd string) tea.Cmd {
+	data, mode := decodeCmd(cmd)
 	return func() tea.Msg {
-		return events.SendMsg{Data: cmd, FromCmdHist: true}
+		return events.SendMsg{Data: data, Mode: mode, FromCmdHist: true}
 	}
 }
 
@@ -317,6 +387,19 @@ func (m *Model) updateCmdHistView() {
 		if i < len(m.cmdHistMatchIdx) {
 			idx = m.cmdHistMatchIdx[i]
 		}
+
+		// match indexes refer to the command data, shift them behind the mode tag
+		data, mode := decodeCmd(cmd)
+		tag := modeTag(mode)
+		cmd = tag + data
+		if tag != "" {
+			tagged := make([]int, len(idx))
+			for k, ix := range idx {
+				tagged[k] = ix + len(tag)
+			}
+			idx = tagged
+		}
+
 		if i == m.cmdHistIndex {
 			const prefix = "> "
 			shifted := make([]int, len(idx))
@@ -335,7 +418,11 @@ func (m *Model) updateCmdHistView() {
 			}
 			cmdHistLines[i] = zone.Mark(strconv.Itoa(i), line)
 		} else {
-			line := highlightMatches(cmd, idx, lipgloss.NewStyle(), styles.SearchHighlightStyle)
+			base := lipgloss.NewStyle()
+			if m.global[m.cmdHistFiltered[i]] {
+				base = styles.BlurredPromtStyle
+			}
+			line := highlightMatches(cmd, idx, base, styles.SearchHighlightStyle)
 			cmdHistLines[i] = zone.Mark(strconv.Itoa(i), line)
 		}
 	}
@@ -432,6 +519,7 @@ func (m *Model) SetPopupOpen(open bool) {
 // already exisiting in the hist. If cmd is found, it will be moved to the end.
 func (m *Model) AddCmd(newCmd string) {
 	log.Printf("add command: %s\n", newCmd)
+	delete(m.global, newCmd)
 	foundIndex := -1
 	for i, cmd := range m.cmdHist {
 		if cmd == newCmd {
@@ -450,8 +538,19 @@ func (m *Model) AddCmd(newCmd string) {
 	m.ResetVp(true)
 }
 
+// GetCmdHist returns the own command history without the commands taken
+// from the global pool.
 func (m Model) GetCmdHist() []string {
-	return m.cmdHist
+	if len(m.global) == 0 {
+		return m.cmdHist
+	}
+	var cmdHist []string
+	for _, cmd := range m.cmdHist {
+		if !m.global[cmd] {
+			cmdHist = append(cmdHist, cmd)
+		}
+	}
+	return cmdHist
 }
 
 // GetSelectedCmd returns the currently highlighted command,
@@ -1,73 +1,166 @@
 package internal
 
 import (
+	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"strings"
+	"time"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mahlburgc/teaterm/internal/macros"
+	"github.com/mahlburgc/teaterm/internal/triggers"
 )
 
 type Config struct {
-	CmdHistoryLines []string
+	CmdHistoryLines       []string
+	GlobalCmdHistoryLines []string
+	Settings
+	// named profiles, selected with -P
+	Profiles map[string]Settings `toml:"profiles"`
 }
 
-// Return the path to the config file.
+// Settings holds all options that can be set in the config file, either
+// globally or in a profile. Unset options are nil, the command line defaults
+// are used for them.
+type Settings struct {
+	Port     *string `toml:"port"`
+	BaudRate *int    `toml:"baudrate"`
+	DataBits *int    `toml:"databits"`
+	Parity   *string `toml:"parity"`
+	StopBits *string `toml:"stopbits"`
+	DTR      *bool   `toml:"dtr"`
+	RTS      *bool   `toml:"rts"`
+
+	// line ending of sent messages
+	LineEnding *string `toml:"eol"`
+	// split received data into messages
+	Framing *string `toml:"framing"`
+	// show unterminated received data after this idle time
+	IdleFlush *time.Duration `toml:"idle"`
+	// split received lines longer than this number of bytes for display
+	MaxLine *int `toml:"maxline"`
+
+	Timestamp   *bool   `toml:"timestamps"`
+	ShowEscapes *bool   `toml:"escapes"`
+	Logfile     *bool   `toml:"log"`
+	Logfilepath *string `toml:"logdir"`
+	// text, plain, hex or jsonl
+	LogFormat *string `toml:"logformat"`
+	// start a new log file segment at this size, like "100M", or after this time
+	LogSize   *string        `toml:"logsize"`
+	LogRotate *time.Duration `toml:"logrotate"`
+	// number of finished log file segments kept, compressed with gzip
+	LogKeep *int  `toml:"logkeep"`
+	LogGzip *bool `toml:"loggzip"`
+	// command history file
+	HistoryFile *string `toml:"history"`
+	// also search the commands of all profiles and ports
+	GlobalHistory *bool `toml:"globalhistory"`
+	// maximum number of stored commands per history file
+	HistorySize *int `toml:"historysize"`
+	// mirror the session to a pseudo-terminal for other programs
+	Pty *bool `toml:"pty"`
+	// share the session with TCP clients, "[host]:port[,ro|rw]"
+	Share []string `toml:"share"`
+
+	// predefined commands, bound to keys and shown in the favorites bar
+	Macros []macros.Macro `toml:"macros"`
+	// automatic responses to received data
+	Triggers []triggers.Trigger `toml:"triggers"`
+}
+
+// overlay returns the settings with all options replaced that are set in o.
+func (s Settings) overlay(o Settings) Settings {
+	sv := reflect.ValueOf(&s).Elem()
+	ov := reflect.ValueOf(o)
+	for i := range ov.NumField() {
+		if !ov.Field(i).IsNil() {
+			sv.Field(i).Set(ov.Field(i))
+		}
+	}
+	return s
+}
+
+// profile returns the global settings overlaid with the settings of the
+// named profile. An empty name selects the global settings only.
+func (c Config) profile(name string) (Settings, error) {
+	if name == "" {
+		return c.Settings, nil
+	}
+
+	p, ok := c.Profiles[name]
+	if !ok {
+		names := make([]string, 0, len(c.Profiles))
+		for n := range c.Profiles {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+		if len(names) == 0 {
+			return Settings{}, fmt.Errorf("unknown profile %q, no profiles defined in %s", name, getConfigFilePath())
+		}
+		return Settings{}, fmt.Errorf("unknown profile %q, available profiles: %s", name, strings.Join(names, ", "))
+	}
+
+	return c.Settings.overlay(p), nil
+}
+
+// Return the path to the config directory.
 // If the path does not exist, it will be created.
-func getConfigFilePath() string {
+func getConfigDir() string {
 	homedir, err := os.UserHomeDir()
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	cmdHistBasePath := homedir + "/.config/teaterm/"
-	cmdHistFileName := "cmdhistroy.conf"
+	configDir := homedir + "/.config/teaterm/"
 
-	err = os.MkdirAll(cmdHistBasePath, os.ModePerm)
+	err = os.MkdirAll(configDir, os.ModePerm)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	return cmdHistBasePath + cmdHistFileName
+	return configDir
 }
 
-// Setup / load the teaterm configuration.
-// Currently config only contains the command history.
-func GetConfig() Config {
-	var config Config
-	filepath := getConfigFilePath()
+// Return the path to the config file.
+func getConfigFilePath() string {
+	return getConfigDir() + "config.toml"
+}
 
-	cmdHist, err := os.ReadFile(filepath)
-	if err != nil {
-		if os.IsNotExist(err) {
-			cmdHist = nil
-		} else {
-			log.Fatal(err)
+// expandHome replaces a leading "~/" with the home directory.
+func expandHome(path string) string {
+	if rest, ok := strings.CutPrefix(path, "~/"); ok {
+		if homedir, err := os.UserHomeDir(); err == nil {
+			return filepath.Join(homedir, rest)
 		}
 	}
+	return path
+}
 
-	if cmdHist != nil {
-		// Trim leading/trailing whitespace
-		trimmedCmdHist := strings.TrimSpace(string(cmdHist))
-		// Split the string by the newline character
-		config.CmdHistoryLines = strings.Split(trimmedCmdHist, "\n")
+// Setup / load the teaterm configuration.
+// The config contains the settings and profiles from the config file.
+// The command history is loaded separately, as its file depends on the profile.
+func GetConfig() Config {
+	config, err := loadConfigFile(getConfigFilePath())
+	if err != nil {
+		log.Fatal(err)
 	}
 	return config
 }
 
-// Store the current config.
-// Saves the last x elemets of current command history for next session.
-func StoreConfig(cmdHist []string) {
-	const maxStoredCmds = 500
-	if len(cmdHist) > maxStoredCmds {
-		start := len(cmdHist) - maxStoredCmds
-		cmdHist = cmdHist[start:]
-	}
-
-	filepath := getConfigFilePath()
-
-	fileContent := strings.Join(cmdHist, "\n") + "\n"
+func loadConfigFile(path string) (Config, error) {
+	var config Config
 
-	err := os.WriteFile(filepath, []byte(fileContent), 0o644)
-	if err != nil {
-		log.Fatal(err)
+	meta, err := toml.DecodeFile(path, &config)
+	if err != nil && !os.IsNotExist(err) {
+		return Config{}, fmt.Errorf("%s: %v", path, err)
+	}
+	if undecoded := meta.Undecoded(); len(undecoded) > 0 {
+		return Config{}, fmt.Errorf("%s: unknown config key %q", path, undecoded[0].String())
 	}
+	return config, nil
 }
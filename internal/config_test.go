@@ -0,0 +1,173 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/macros"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.toml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestConfigProfiles(t *testing.T) {
+	path := writeConfig(t, `
+baudrate = 9600
+eol = "lf"
+
+[profiles.uboot]
+port = "/dev/serial/by-id/usb-FTDI-if00-port0"
+baudrate = 115200
+timestamps = true
+history = "~/uboot.history"
+`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	global, err := config.profile("")
+	if err != nil || *global.BaudRate != 9600 || global.Port != nil {
+		t.Errorf("global settings = %+v, %v", global, err)
+	}
+
+	uboot, err := config.profile("uboot")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *uboot.BaudRate != 115200 || *uboot.LineEnding != "lf" || !*uboot.Timestamp ||
+		*uboot.Port != "/dev/serial/by-id/usb-FTDI-if00-port0" {
+		t.Errorf("profile settings not overlaid on global settings: %+v", uboot)
+	}
+
+	if _, err := config.profile("modem"); err == nil || !strings.Contains(err.Error(), "uboot") {
+		t.Errorf("unknown profile error = %v, want list of available profiles", err)
+	}
+}
+
+func TestConfigUnknownKey(t *testing.T) {
+	path := writeConfig(t, "[profiles.uboot]\nbaud = 115200\n")
+	if _, err := loadConfigFile(path); err == nil || !strings.Contains(err.Error(), "profiles.uboot.baud") {
+		t.Errorf("loadConfigFile error = %v, want unknown key error", err)
+	}
+}
+
+func TestCmdHistoryFiles(t *testing.T) {
+	if got := sanitizeFileName("/dev/ttyUSB0"); got != "dev_ttyUSB0" {
+		t.Errorf("sanitizeFileName = %q", got)
+	}
+	if got := portIdentity("/dev/serial/by-id/usb-FTDI-if00-port0"); got != "usb-FTDI-if00-port0" {
+		t.Errorf("portIdentity = %q", got)
+	}
+
+	dir := t.TempDir()
+	h := cmdHistoryFiles{
+		file:       filepath.Join(dir, "profiles", "uboot"),
+		globalFile: filepath.Join(dir, "global"),
+		global:     []string{"a", "b", "c"},
+		size:       3,
+	}
+	h.store([]string{"b", "d"})
+
+	if got := strings.Join(LoadCmdHistory(h.file), "|"); got != "b|d" {
+		t.Errorf("history = %q, want %q", got, "b|d")
+	}
+	if got := strings.Join(LoadCmdHistory(h.globalFile), "|"); got != "c|b|d" {
+		t.Errorf("global history = %q, want %q", got, "c|b|d")
+	}
+}
+
+func TestConfigMacros(t *testing.T) {
+	path := writeConfig(t, `
+[[macros]]
+name = "boot"
+key = "f1"
+commands = [
+  { send = "reset" },
+  { send = "\\x03", mode = "esc", delay = "2s" },
+]
+
+[[profiles.uboot.macros]]
+name = "env"
+commands = [{ send = "printenv" }]
+`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := macros.Validate(config.Macros); err != nil {
+		t.Fatal(err)
+	}
+	if got := config.Macros[0].Commands[1]; got.Send != `\x03` || got.Mode != "esc" || got.Delay != 2*time.Second {
+		t.Errorf("macro command = %+v", got)
+	}
+
+	uboot, err := config.profile("uboot")
+	if err != nil || len(uboot.Macros) != 1 || uboot.Macros[0].Name != "env" {
+		t.Errorf("profile macros = %+v, %v", uboot.Macros, err)
+	}
+}
+
+func TestConfigLogRotation(t *testing.T) {
+	path := writeConfig(t, `
+logsize = "100M"
+logrotate = "1h"
+logkeep = 24
+loggzip = true
+`)
+
+	config, err := loadConfigFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var flags Flags
+	flags.applySettings(config.Settings, map[string]bool{"logkeep": true})
+	rotation, err := flags.LogRotation()
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := logrotate.Config{MaxSize: 100 << 20, Interval: time.Hour, Gzip: true}
+	if rotation != want {
+		t.Errorf("log rotation = %+v, want %+v", rotation, want)
+	}
+
+	flags.LogSize = "100X"
+	if _, err := flags.LogRotation(); err == nil {
+		t.Error("invalid log size accepted")
+	}
+}
+
+func TestMigrateCmdHistory(t *testing.T) {
+	dir := t.TempDir()
+	oldPath := filepath.Join(dir, "cmdhistroy.conf")
+	path := filepath.Join(dir, "history", "ports", "dev_ttyUSB0")
+	globalPath := filepath.Join(dir, "history", "global")
+	if err := os.WriteFile(oldPath, []byte("a\nb\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := migrateCmdHistory(oldPath, path, globalPath); err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Join(LoadCmdHistory(path), "|"); got != "a|b" {
+		t.Errorf("history = %q, want %q", got, "a|b")
+	}
+	if got := strings.Join(LoadCmdHistory(globalPath), "|"); got != "a|b" {
+		t.Errorf("global history = %q, want %q", got, "a|b")
+	}
+	if _, err := os.Stat(oldPath); !os.IsNotExist(err) {
+		t.Errorf("old history file still exists")
+	}
+}
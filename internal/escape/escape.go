@@ -0,0 +1,116 @@
+// Package escape converts between raw bytes and their C-style escaped text
+// representation, e.g. "\r\n" <-> `\r\n` or "\x00" <-> `\x00`.
+package escape
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var escapes = map[byte]byte{
+	'0':  0x00,
+	'a':  '\a',
+	'b':  '\b',
+	'e':  0x1b,
+	'f':  '\f',
+	'n':  '\n',
+	'r':  '\r',
+	't':  '\t',
+	'v':  '\v',
+	'\\': '\\',
+}
+
+// Unescape parses a string with C-style escape sequences into raw bytes.
+// Supported are \0, \a, \b, \e, \f, \n, \r, \t, \v, \\ and \xHH.
+func Unescape(s string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		if s[i] != '\\' {
+			sb.WriteByte(s[i])
+			continue
+		}
+
+		if i+1 >= len(s) {
+			return "", fmt.Errorf("incomplete escape sequence at position %d", i)
+		}
+		i++
+
+		if s[i] == 'x' {
+			if i+2 >= len(s) {
+				return "", fmt.Errorf("incomplete hex escape at position %d", i-1)
+			}
+			b, err := strconv.ParseUint(s[i+1:i+3], 16, 8)
+			if err != nil {
+				return "", fmt.Errorf("invalid hex escape %q at position %d", s[i-1:i+3], i-1)
+			}
+			sb.WriteByte(byte(b))
+			i += 2
+			continue
+		}
+
+		b, ok := escapes[s[i]]
+		if !ok {
+			return "", fmt.Errorf("unknown escape sequence %q at position %d", s[i-1:i+1], i-1)
+		}
+		sb.WriteByte(b)
+	}
+
+	return sb.String(), nil
+}
+
+// Quote returns the escaped text representation of the given bytes.
+// Printable ASCII characters are kept, all others are escaped.
+// The result can be converted back with Unescape.
+func Quote(s string) string {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); i++ {
+		b := s[i]
+		switch {
+		case b == '\\':
+			sb.WriteString(`\\`)
+		case b == '\r':
+			sb.WriteString(`\r`)
+		case b == '\n':
+			sb.WriteString(`\n`)
+		case b == '\t':
+			sb.WriteString(`\t`)
+		case b == 0:
+			sb.WriteString(`\0`)
+		case b >= 32 && b < 127:
+			sb.WriteByte(b)
+		default:
+			sb.WriteString(fmt.Sprintf(`\x%02x`, b))
+		}
+	}
+
+	return sb.String()
+}
+
+// ParseHex parses hex bytes like "01 0A FF" into raw bytes.
+// Bytes may be separated by whitespace, a group of digits without separator
+// like "010AFF" is read as consecutive bytes.
+func ParseHex(s string) (string, error) {
+	var sb strings.Builder
+
+	for i := 0; i < len(s); {
+		if s[i] == ' ' || s[i] == '\t' {
+			i++
+			continue
+		}
+
+		if i+1 >= len(s) || s[i+1] == ' ' || s[i+1] == '\t' {
+			return "", fmt.Errorf("incomplete hex byte at position %d", i)
+		}
+		b, err := strconv.ParseUint(s[i:i+2], 16, 8)
+		if err != nil {
+			return "", fmt.Errorf("invalid hex byte %q at position %d", s[i:i+2], i)
+		}
+		sb.WriteByte(byte(b))
+		i += 2
+	}
+
+	return sb.String(), nil
+}
@@ -0,0 +1,73 @@
+package escape
+
+import "testing"
+
+func TestUnescape(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`hello`, "hello", false},
+		{`\r\n`, "\r\n", false},
+		{`\x1b[A`, "\x1b[A", false},
+		{`\0`, "\x00", false},
+		{`a\\b`, `a\b`, false},
+		{`\xff\xFF`, "\xff\xff", false},
+		{`\`, "", true},
+		{`\x1`, "", true},
+		{`\xzz`, "", true},
+		{`\q`, "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := Unescape(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("Unescape(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("Unescape(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
+
+func TestQuoteRoundTrip(t *testing.T) {
+	for _, s := range []string{"", "\r\n", "\x00", "AT\r", "\x1b[A\xff", `back\slash`} {
+		got, err := Unescape(Quote(s))
+		if err != nil || got != s {
+			t.Errorf("Unescape(Quote(%q)) = %q, %v", s, got, err)
+		}
+	}
+}
+
+func TestParseHex(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{"01 0A FF", "\x01\x0a\xff", false},
+		{"010aff", "\x01\x0a\xff", false},
+		{"  1b\t5b 41 ", "\x1b[A", false},
+		{"", "", false},
+		{"1", "", true},
+		{"01 2 03", "", true},
+		{"0g", "", true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseHex(tt.in)
+		if tt.wantErr {
+			if err == nil {
+				t.Errorf("ParseHex(%q) = %q, want error", tt.in, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Errorf("ParseHex(%q) = %q, %v, want %q", tt.in, got, err, tt.want)
+		}
+	}
+}
@@ -1,33 +1,367 @@
 package internal
 
-import "flag"
+import (
+	"flag"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/macros"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/share"
+	"github.com/mahlburgc/teaterm/internal/triggers"
+)
 
 type Flags struct {
+	Script      string // script file of the run command
+	Replay      string // log file of the replay command
+	View        string // file of the view command
+	Speed       float64
+	Step        bool
 	List        bool
+	Pipe        bool
+	Pty         bool
+	Share       []string
+	Profile     string
 	Port        string
 	Timestamp   bool
 	Logfile     bool
 	Logfilepath string
 	ShowEscapes bool
+	HexView     bool
+	LogFormat   string
+	LogSize     string        // size of the log segments, empty for no limit
+	LogRotate   time.Duration // time of the log segments, 0 for no limit
+	LogKeep     int
+	LogGzip     bool
+	BaudRate    int
+	DataBits    int
+	Parity      string
+	StopBits    string
+	DTR         bool
+	RTS         bool
+	LineEnding  string
+	Framing     string
+	IdleFlush   time.Duration
+	MaxLine     int
+	HistoryFile string
+	GlobalHist  bool
+	HistSize    int
+	Macros      []macros.Macro
+	Triggers    []triggers.Trigger
 }
 
 // Get all command line arguments.
-func GetFlags() Flags {
+// Options that are not given on the command line are taken from the selected
+// profile or the global settings of the config file.
+func GetFlags(config Config) (Flags, error) {
 	listArg := flag.Bool("l", false, "list available ports")
-	portArg := flag.String("p", "/dev/ttyUSB0", "serial port")
+	pipeArg := flag.Bool("pipe", false, "headless mode: send the lines of stdin, print received lines to stdout")
+	ptyArg := flag.Bool("pty", false, "mirror the session to a pseudo-terminal /tmp/teaterm-<port> for other programs")
+	var shareArg listFlag
+	flag.Var(&shareArg, "share", "share the session with TCP clients on `[host]:port[,ro|rw]`, may be repeated")
+	profileArg := flag.String("P", "", "use the named profile of the config file")
+	portArg := flag.String("p", "/dev/ttyUSB0", "serial port, tcp://, telnet:// or rfc2217://host:port, unix:/path or exec:command")
 	timestampArg := flag.Bool("t", false, "show timestamp")
 	logfileArg := flag.Bool("log", false, "create log file")
 	logfilePathArg := flag.String("logpath", ".", "specify logfile dir")
 	showEscapesArg := flag.Bool("e", false, "print escape / non ascii charactres")
+	hexViewArg := flag.Bool("x", false, "show sent and received messages as hex dump")
+	logFormatArg := flag.String("logformat", "text", "log file format (text, plain, hex or jsonl)")
+	logSizeArg := flag.String("logsize", "", "start a new log file segment at this size, e.g. 100M")
+	logRotateArg := flag.Duration("logrotate", 0, "start a new log file segment after this time, e.g. 1h")
+	logKeepArg := flag.Int("logkeep", 0, "number of finished log file segments kept, 0 keeps all")
+	logGzipArg := flag.Bool("loggzip", false, "compress finished log file segments with gzip")
+	baudRateArg := flag.Int("b", 115200, "baud rate")
+	dataBitsArg := flag.Int("databits", 8, "data bits (5, 6, 7 or 8)")
+	parityArg := flag.String("parity", "none", "parity (none, odd, even, mark or space)")
+	stopBitsArg := flag.String("stopbits", "1", "stop bits (1, 1.5 or 2)")
+	dtrArg := flag.Bool("dtr", true, "initial DTR state")
+	rtsArg := flag.Bool("rts", true, "initial RTS state")
+	framingArg := flag.String("framing", "lf", "split received data on lf, cr, crlf, any (cr or lf), any:<bytes>, delim:<bytes>, fixed:<n> or timeout:<duration>")
+	idleFlushArg := flag.Duration("idle", 200*time.Millisecond, "show unterminated received data after this idle time (0 disables)")
+	maxLineArg := flag.Int("maxline", 4096, "split received lines longer than this number of bytes for display (0 disables)")
+	globalHistArg := flag.Bool("globalhist", false, "also search the command history of all profiles and ports")
+	histSizeArg := flag.Int("histsize", 500, "maximum number of stored commands")
+	lineEndingArg := flag.String("eol", "crlf", "line ending of sent messages (cr, lf, crlf, none or escaped bytes like \\x00)")
+	speedArg := flag.Float64("speed", 1, "replay speed factor, e.g. 10 or 0.5")
+	stepArg := flag.Bool("step", false, "start the replay paused, step through the messages")
 
-	flag.Parse()
+	flag.Usage = usage
+	args, err := parseArgs(os.Args[1:])
+	if err != nil {
+		return Flags{}, err
+	}
 
-	return Flags{
+	flags := Flags{
 		List:        *listArg,
+		Pipe:        *pipeArg,
+		Pty:         *ptyArg,
+		Share:       shareArg,
+		Profile:     *profileArg,
 		Port:        *portArg,
 		Timestamp:   *timestampArg,
 		Logfile:     *logfileArg,
 		Logfilepath: *logfilePathArg,
 		ShowEscapes: *showEscapesArg,
+		HexView:     *hexViewArg,
+		LogFormat:   *logFormatArg,
+		LogSize:     *logSizeArg,
+		LogRotate:   *logRotateArg,
+		LogKeep:     *logKeepArg,
+		LogGzip:     *logGzipArg,
+		BaudRate:    *baudRateArg,
+		DataBits:    *dataBitsArg,
+		Parity:      *parityArg,
+		StopBits:    *stopBitsArg,
+		DTR:         *dtrArg,
+		RTS:         *rtsArg,
+		LineEnding:  *lineEndingArg,
+		Framing:     *framingArg,
+		IdleFlush:   *idleFlushArg,
+		MaxLine:     *maxLineArg,
+		GlobalHist:  *globalHistArg,
+		HistSize:    *histSizeArg,
+		Speed:       *speedArg,
+		Step:        *stepArg,
+	}
+
+	switch {
+	case len(args) == 2 && args[0] == "run":
+		flags.Script = args[1]
+	case len(args) > 0 && args[0] == "run":
+		return Flags{}, fmt.Errorf("usage: teaterm run <script> [flags]")
+	case len(args) == 2 && args[0] == "replay":
+		flags.Replay = args[1]
+	case len(args) > 0 && args[0] == "replay":
+		return Flags{}, fmt.Errorf("usage: teaterm replay <file.jsonl> [flags]")
+	case len(args) == 2 && args[0] == "view":
+		flags.View = args[1]
+	case len(args) > 0 && args[0] == "view":
+		return Flags{}, fmt.Errorf("usage: teaterm view <file> [flags]")
+	case len(args) > 0:
+		return Flags{}, fmt.Errorf("unknown command %q, run teaterm -h for help", args[0])
+	}
+
+	if flags.Pipe && flags.Script != "" {
+		return Flags{}, fmt.Errorf("-pipe can not be used with the run command")
+	}
+	if flags.Pipe && flags.Replay != "" {
+		return Flags{}, fmt.Errorf("-pipe can not be used with the replay command")
+	}
+	if flags.Pipe && flags.View != "" {
+		return Flags{}, fmt.Errorf("-pipe can not be used with the view command")
+	}
+	if flags.Speed <= 0 {
+		return Flags{}, fmt.Errorf("invalid replay speed %g", flags.Speed)
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+
+	settings, err := config.profile(flags.Profile)
+	if err != nil {
+		return Flags{}, err
+	}
+	flags.applySettings(settings, set)
+
+	if flags.Replay != "" {
+		// the replay port ends every received message with lf
+		flags.Port = "replay:" + flags.Replay
+		flags.Framing = "lf"
+	}
+
+	if flags.HistSize < 0 {
+		return Flags{}, fmt.Errorf("invalid history size %d", flags.HistSize)
+	}
+	if _, err := flags.LogRotation(); err != nil {
+		return Flags{}, err
+	}
+
+	return flags, nil
+}
+
+// listFlag is a flag that may be given several times.
+type listFlag []string
+
+func (l *listFlag) String() string {
+	return strings.Join(*l, " ")
+}
+
+func (l *listFlag) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintln(out, "Usage:")
+	fmt.Fprintln(out, "  teaterm [flags]                start an interactive session")
+	fmt.Fprintln(out, "  teaterm run <script> [flags]   run a send/expect script")
+	fmt.Fprintln(out, "  teaterm replay <file> [flags]  replay a JSONL serial log")
+	fmt.Fprintln(out, "  teaterm view <file> [flags]    view a log file read-only")
+	fmt.Fprintln(out, "  teaterm -pipe [flags]          send stdin to the port, print received lines to stdout")
+	fmt.Fprintln(out, "\nFlags:")
+	flag.PrintDefaults()
+}
+
+// parseArgs parses the flags and returns the other arguments.
+// Flags may be given before and after the other arguments.
+func parseArgs(args []string) ([]string, error) {
+	var rest []string
+	for {
+		if err := flag.CommandLine.Parse(args); err != nil {
+			return nil, err
+		}
+		if flag.NArg() == 0 {
+			return rest, nil
+		}
+		rest = append(rest, flag.Arg(0))
+		args = flag.Args()[1:]
+	}
+}
+
+// applySettings overwrites all flags with the given config settings,
+// that are not set on the command line.
+func (flags *Flags) applySettings(config Settings, set map[string]bool) {
+	useConfig(&flags.Port, config.Port, set["p"])
+	useConfig(&flags.BaudRate, config.BaudRate, set["b"])
+	useConfig(&flags.DataBits, config.DataBits, set["databits"])
+	useConfig(&flags.Parity, config.Parity, set["parity"])
+	useConfig(&flags.StopBits, config.StopBits, set["stopbits"])
+	useConfig(&flags.DTR, config.DTR, set["dtr"])
+	useConfig(&flags.RTS, config.RTS, set["rts"])
+	useConfig(&flags.LineEnding, config.LineEnding, set["eol"])
+	useConfig(&flags.Framing, config.Framing, set["framing"])
+	useConfig(&flags.IdleFlush, config.IdleFlush, set["idle"])
+	useConfig(&flags.MaxLine, config.MaxLine, set["maxline"])
+	useConfig(&flags.Timestamp, config.Timestamp, set["t"])
+	useConfig(&flags.ShowEscapes, config.ShowEscapes, set["e"])
+	useConfig(&flags.Logfile, config.Logfile, set["log"])
+	useConfig(&flags.Logfilepath, config.Logfilepath, set["logpath"])
+	useConfig(&flags.LogFormat, config.LogFormat, set["logformat"])
+	useConfig(&flags.LogSize, config.LogSize, set["logsize"])
+	useConfig(&flags.LogRotate, config.LogRotate, set["logrotate"])
+	useConfig(&flags.LogKeep, config.LogKeep, set["logkeep"])
+	useConfig(&flags.LogGzip, config.LogGzip, set["loggzip"])
+	flags.Logfilepath = expandHome(flags.Logfilepath)
+
+	useConfig(&flags.GlobalHist, config.GlobalHistory, set["globalhist"])
+	useConfig(&flags.HistSize, config.HistorySize, set["histsize"])
+	useConfig(&flags.Pty, config.Pty, set["pty"])
+	if !set["share"] && config.Share != nil {
+		flags.Share = config.Share
+	}
+
+	flags.Macros = config.Macros
+	flags.Triggers = config.Triggers
+
+	flags.HistoryFile = getCmdHistFilePath(flags.Profile, flags.Port)
+	useConfig(&flags.HistoryFile, config.HistoryFile, false)
+	flags.HistoryFile = expandHome(flags.HistoryFile)
+}
+
+// useConfig overwrites the flag value with the config value,
+// if the config value exists and the flag was not set on the command line.
+func useConfig[T any](flag *T, configVal *T, flagSet bool) {
+	if configVal != nil && !flagSet {
+		*flag = *configVal
 	}
 }
+
+// ShareListeners returns the listeners of the share server.
+func (f Flags) ShareListeners() ([]share.Listener, error) {
+	var listeners []share.Listener
+	for _, spec := range f.Share {
+		l, err := share.ParseListener(spec)
+		if err != nil {
+			return nil, err
+		}
+		listeners = append(listeners, l)
+	}
+	return listeners, nil
+}
+
+// CheckLogFormat validates the log file format.
+func (f Flags) CheckLogFormat() error {
+	if slices.Contains(msglog.LogFormats, msglog.LogFormat(f.LogFormat)) {
+		return nil
+	}
+	return fmt.Errorf("invalid log format %q, use text, plain, hex or jsonl", f.LogFormat)
+}
+
+// LogRotation returns when the log file is split into segments and what
+// happens with the finished ones.
+func (f Flags) LogRotation() (logrotate.Config, error) {
+	config := logrotate.Config{Interval: f.LogRotate, Keep: f.LogKeep, Gzip: f.LogGzip}
+	if f.LogSize != "" {
+		size, err := logrotate.ParseSize(f.LogSize)
+		if err != nil {
+			return logrotate.Config{}, fmt.Errorf("invalid log size: %v", err)
+		}
+		config.MaxSize = size
+	}
+	if config.Interval < 0 {
+		return logrotate.Config{}, fmt.Errorf("invalid log rotation time %v", config.Interval)
+	}
+	if config.Keep < 0 {
+		return logrotate.Config{}, fmt.Errorf("invalid number of kept log files %d", config.Keep)
+	}
+	return config, nil
+}
+
+// CheckMacros validates the macros of the config file.
+func (f Flags) CheckMacros() error {
+	if err := macros.Validate(f.Macros); err != nil {
+		return fmt.Errorf("%s: %v", getConfigFilePath(), err)
+	}
+	return nil
+}
+
+// CheckTriggers validates the triggers of the config file.
+func (f Flags) CheckTriggers() error {
+	if err := triggers.Validate(f.Triggers); err != nil {
+		return fmt.Errorf("%s: %v", getConfigFilePath(), err)
+	}
+	return nil
+}
+
+// SessionSettings validates the port related flags and returns the resulting
+// session settings.
+func (f Flags) SessionSettings() (session.Settings, error) {
+	mode, err := session.NewMode(f.BaudRate, f.DataBits, f.Parity, f.StopBits, f.DTR, f.RTS)
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	lineEnding, err := session.ParseLineEnding(f.LineEnding)
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	framing, err := session.ParseFraming(f.Framing)
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	if f.IdleFlush < 0 {
+		return session.Settings{}, fmt.Errorf("invalid idle time %s", f.IdleFlush)
+	}
+
+	if f.MaxLine < 0 {
+		return session.Settings{}, fmt.Errorf("invalid maximum line length %d", f.MaxLine)
+	}
+
+	return session.Settings{
+		Port:          f.Port,
+		Mode:          mode,
+		LineEnding:    lineEnding,
+		Framing:       framing,
+		IdleFlush:     f.IdleFlush,
+		MaxLineLength: f.MaxLine,
+	}, nil
+}
@@ -0,0 +1,47 @@
+// Package handover passes the events of a background source, like a port or
+// the share clients, to the tea command waiting for them. When the editor
+// restarts the program, the commands of the old program may still wait for
+// the next event. The event goes to the latest waiter instead, so it is not
+// lost with the old program.
+package handover
+
+import "sync"
+
+// Latest hands the result of a blocking fetch to the latest caller of Wait.
+// The zero value is ready to use.
+type Latest[T any] struct {
+	mu      sync.Mutex
+	waiting chan T // gets the result of the running fetch, nil if none runs
+}
+
+// Wait blocks until fetch returns and returns its result. Only one fetch
+// runs at a time: if another Wait is pending, its fetch goes on and the
+// result goes to the latest Wait. The Waits before return with ok false.
+func (l *Latest[T]) Wait(fetch func() T) (v T, ok bool) {
+	ch := make(chan T, 1)
+	l.mu.Lock()
+	fetching := l.waiting != nil
+	if fetching {
+		// the pending Wait fetches the result and hands it over
+		close(l.waiting)
+	}
+	l.waiting = ch
+	l.mu.Unlock()
+
+	if fetching {
+		v, ok = <-ch
+		return v, ok
+	}
+
+	v = fetch()
+	l.mu.Lock()
+	latest := l.waiting
+	l.waiting = nil
+	l.mu.Unlock()
+	if latest != ch {
+		latest <- v
+		var zero T
+		return zero, false
+	}
+	return v, true
+}
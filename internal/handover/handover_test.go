@@ -0,0 +1,55 @@
+package handover
+
+import (
+	"testing"
+	"time"
+)
+
+type result struct {
+	v  string
+	ok bool
+}
+
+func TestWait(t *testing.T) {
+	var l Latest[string]
+	if v, ok := l.Wait(func() string { return "a" }); v != "a" || !ok {
+		t.Errorf("Wait = %q, %v, want a", v, ok)
+	}
+}
+
+// A Wait started while another one is pending gets the result, the pending
+// Wait returns without it.
+func TestWaitHandover(t *testing.T) {
+	var l Latest[string]
+	events := make(chan string)
+	fetch := func() string { return <-events }
+
+	first, second := make(chan result), make(chan result)
+	go func() {
+		v, ok := l.Wait(fetch)
+		first <- result{v, ok}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		v, ok := l.Wait(func() string {
+			t.Error("second fetch while the first one runs")
+			return ""
+		})
+		second <- result{v, ok}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	events <- "hello"
+
+	if res := <-second; !res.ok || res.v != "hello" {
+		t.Errorf("second Wait = %q, %v, want hello", res.v, res.ok)
+	}
+	if res := <-first; res.ok {
+		t.Errorf("first Wait got %q, want nothing", res.v)
+	}
+
+	// the next Wait fetches again
+	go func() { events <- "next" }()
+	if v, ok := l.Wait(fetch); v != "next" || !ok {
+		t.Errorf("next Wait = %q, %v, want next", v, ok)
+	}
+}
@@ -0,0 +1,191 @@
+package internal
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// The command history is stored per profile or, without profile, per port.
+// Additionally all commands are collected in a global pool, that can be
+// searched together with the own history.
+//
+//	~/.config/teaterm/history/global             commands of all sessions
+//	~/.config/teaterm/history/profiles/<name>    commands of a profile
+//	~/.config/teaterm/history/ports/<identity>   commands of a port
+
+// Return the path of the command history file used before per profile and
+// per port histories existed.
+func getOldCmdHistFilePath() string {
+	return getConfigDir() + "cmdhistroy.conf"
+}
+
+func getCmdHistDir() string {
+	return filepath.Join(getConfigDir(), "history")
+}
+
+// GlobalHistoryFile returns the path of the command history pool of all
+// profiles and ports.
+func GlobalHistoryFile() string {
+	return filepath.Join(getCmdHistDir(), "global")
+}
+
+// Return the default path of the command history file of a session.
+// A profile has its own history, otherwise the history of the port is used.
+func getCmdHistFilePath(profile string, port string) string {
+	if profile != "" {
+		return filepath.Join(getCmdHistDir(), "profiles", sanitizeFileName(profile))
+	}
+	return filepath.Join(getCmdHistDir(), "ports", sanitizeFileName(portIdentity(port)))
+}
+
+// portIdentity returns a stable name for the device behind the port.
+// For USB serial adapters this is the name of the /dev/serial/by-id link,
+// which does not change if the device gets another ttyUSB number.
+// Other ports are identified by their path.
+func portIdentity(port string) string {
+	const byIdDir = "/dev/serial/by-id"
+
+	if filepath.Dir(port) == byIdDir {
+		return filepath.Base(port)
+	}
+
+	realPort, err := filepath.EvalSymlinks(port)
+	if err != nil {
+		return port
+	}
+
+	entries, _ := os.ReadDir(byIdDir)
+	for _, entry := range entries {
+		realPath, err := filepath.EvalSymlinks(filepath.Join(byIdDir, entry.Name()))
+		if err == nil && realPath == realPort {
+			return entry.Name()
+		}
+	}
+	return port
+}
+
+var unsafeFileNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// sanitizeFileName converts e.g. "/dev/ttyUSB0" into "dev_ttyUSB0".
+// Long names like exec: commands are shortened.
+func sanitizeFileName(name string) string {
+	name = strings.Trim(unsafeFileNameChars.ReplaceAllString(name, "_"), "_.")
+	return name[:min(len(name), 128)]
+}
+
+// MigrateCmdHistory moves the command history file of older versions to the
+// history file of the session, so the commands are still suggested after an
+// update. A copy goes to the global pool, if there is no pool yet.
+func MigrateCmdHistory(path string) {
+	if err := migrateCmdHistory(getOldCmdHistFilePath(), path, GlobalHistoryFile()); err != nil {
+		log.Fatal(err)
+	}
+}
+
+func migrateCmdHistory(oldPath string, path string, globalPath string) error {
+	if _, err := os.Stat(oldPath); err != nil {
+		return nil
+	}
+	if _, err := os.Stat(path); err == nil {
+		return nil // the session has its own history already
+	}
+
+	if _, err := os.Stat(globalPath); os.IsNotExist(err) {
+		cmdHist, err := os.ReadFile(oldPath)
+		if err != nil {
+			return err
+		}
+		if err := os.MkdirAll(filepath.Dir(globalPath), os.ModePerm); err != nil {
+			return err
+		}
+		if err := os.WriteFile(globalPath, cmdHist, 0o644); err != nil {
+			return err
+		}
+	}
+
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		return err
+	}
+	if err := os.Rename(oldPath, path); err != nil {
+		return err
+	}
+	log.Printf("Command history migrated from %s to %s", oldPath, path)
+	return nil
+}
+
+// Load the command history from the given file.
+func LoadCmdHistory(path string) []string {
+	cmdHist, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		log.Fatal(err)
+	}
+
+	// Trim leading/trailing whitespace
+	trimmedCmdHist := strings.TrimSpace(string(cmdHist))
+	if trimmedCmdHist == "" {
+		return nil
+	}
+	// Split the string by the newline character
+	return strings.Split(trimmedCmdHist, "\n")
+}
+
+// Store the command history.
+// Saves the last x elemets of current command history for next session.
+func StoreCmdHistory(path string, cmdHist []string, maxStoredCmds int) {
+	if len(cmdHist) > maxStoredCmds {
+		start := len(cmdHist) - maxStoredCmds
+		cmdHist = cmdHist[start:]
+	}
+
+	fileContent := strings.Join(cmdHist, "\n") + "\n"
+
+	err := os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	err = os.WriteFile(path, []byte(fileContent), 0o644)
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// mergeCmdHistory appends the commands to the history. Commands that are
+// already in the history are moved to the end.
+func mergeCmdHistory(cmdHist []string, cmds []string) []string {
+	added := make(map[string]bool, len(cmds))
+	for _, cmd := range cmds {
+		added[cmd] = true
+	}
+
+	merged := make([]string, 0, len(cmdHist)+len(cmds))
+	for _, cmd := range cmdHist {
+		if !added[cmd] {
+			merged = append(merged, cmd)
+		}
+	}
+	return append(merged, cmds...)
+}
+
+// cmdHistoryFiles defines where the command history of a session is stored.
+type cmdHistoryFiles struct {
+	file       string   // history of the profile or port
+	globalFile string   // pool of the commands of all sessions
+	global     []string // pool as loaded on start
+	size       int      // maximum number of commands per file
+}
+
+// Store the history of the session and add its commands to the global pool.
+func (h cmdHistoryFiles) store(cmdHist []string) {
+	if h.file == "" {
+		return
+	}
+	StoreCmdHistory(h.file, cmdHist, h.size)
+	StoreCmdHistory(h.globalFile, mergeCmdHistory(h.global, cmdHist), h.size)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/charmbracelet/lipgloss"
 	"github.com/mahlburgc/teaterm/events"
 	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/session"
 	"github.com/mahlburgc/teaterm/internal/styles"
 )
 
@@ -22,11 +23,16 @@ type Model struct {
 	inputSuggestion    string
 	width              int
 	isMsgLogFilterMode bool
+	filterOnly         bool // nothing can be sent, the input always filters
+	mode               events.InputMode
+	inputErr           error // invalid input in hex or escape mode
 }
 
 const (
 	searchPromt = "Filter: "
 	inputPromt  = "> "
+	escapePromt = "Esc> "
+	hexPromt    = "Hex> "
 )
 
 func New() (m Model) {
@@ -51,14 +57,46 @@ func New() (m Model) {
 	return m
 }
 
+// inputKeys are the bindings passed to the input: its own ones and the ones
+// sharing their keys with the editing keys of the textarea. Replay keys are
+// handled before the input on the replay tab, on other tabs alt+← and alt+→
+// move by words.
+var inputKeys = []key.Binding{
+	keymap.Default.InputModeKey, keymap.Default.SendKey, keymap.Default.ResetKey, keymap.Default.CloseKey,
+	keymap.Default.ToggleHistKey, keymap.Default.FilterMsgLogKey, keymap.Default.AutoCompleteKey,
+	keymap.Default.HistUpKey, keymap.Default.HistDownKey, keymap.Default.DeleteCmdKey,
+	keymap.Default.OpenEditorKey, keymap.Default.DebugKey,
+	keymap.Default.ReplayPauseKey, keymap.Default.ReplayStepKey, keymap.Default.ReplayFasterKey,
+	keymap.Default.ReplaySlowerKey, keymap.Default.ReplayBackKey, keymap.Default.ReplayForwardKey,
+}
+
+// isOtherKey reports whether the key is bound to another component.
+func isOtherKey(msg tea.KeyMsg) bool {
+	if key.Matches(msg, inputKeys...) {
+		return false
+	}
+	for _, group := range keymap.Default.FullHelp() {
+		if key.Matches(msg, group...) {
+			return true
+		}
+	}
+	return false
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 
-	// Ignore specific shortcuts to avoid adding the to the textarea while
-	// using them for navigation.
+	// Ignore the shortcuts of other components to avoid adding them to the
+	// textarea while using them for navigation.
 	if msg, ok := msg.(tea.KeyMsg); ok {
-		switch msg.String() {
-		case "alt+j", "alt+k", "alt+h", "alt+l", "home", "end":
+		if isOtherKey(msg) {
+			return m, nil
+		}
+
+		if key.Matches(msg, keymap.Default.InputModeKey) {
+			if m.ta.Focused() && !m.isMsgLogFilterMode {
+				return m, m.setMode((m.mode + 1) % 3)
+			}
 			return m, nil
 		}
 	}
@@ -74,6 +112,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		if !strings.HasPrefix(m.inputSuggestion, newValue) {
 			m.inputSuggestion = ""
 		}
+		m.validate()
 	}
 
 	// Broadcast the current ta input. The input will be parsed by the command history
@@ -125,11 +164,11 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	case tea.KeyMsg:
 		switch {
 		case key.Matches(msg, keymap.Default.SendKey):
-			if m.ta.Value() == "" {
+			if m.ta.Value() == "" || m.inputErr != nil {
 				return m, nil
 			} else if !m.isMsgLogFilterMode {
 				return m, func() tea.Msg {
-					return events.SendMsg{Data: m.ta.Value(), FromCmdHist: false}
+					return events.SendMsg{Data: m.ta.Value(), Mode: m.mode, FromCmdHist: false}
 				}
 			}
 
@@ -182,14 +221,19 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 
 	case events.SendMsg:
+		// macros and scripts send independently of the input, keep what is typed
+		if msg.Automated {
+			return m, nil
+		}
 		m.inputSuggestion = ""
 		return m, m.Reset()
 
 	case events.HistCmdSelected:
 		if !m.isMsgLogFilterMode {
-			if string(msg) != "" {
-				m.SetValue(string(msg))
-				return m, nil
+			if msg.Data != "" {
+				cmd = m.setMode(msg.Mode)
+				m.SetValue(msg.Data)
+				return m, cmd
 			} else {
 				return m, m.Reset()
 			}
@@ -272,7 +316,13 @@ func (m Model) View() string {
 	// log.Printf("width: %v, ta.width: %v\n", m.width, m.ta.Width())
 	// log.Printf("m.ta.Value: %v\n", m.ta.Value())
 	// log.Printf("conntent: %s\n", content)
-	return styles.AddBorder(vp, "", "", false)
+
+	// show invalid hex or escape input inline
+	var footer string
+	if m.inputErr != nil && !m.isMsgLogFilterMode {
+		footer = styles.ErrMsgStyle.Render(m.inputErr.Error())
+	}
+	return styles.AddBorder(vp, "", footer, true)
 }
 
 func (m *Model) SetWidth(width int) {
@@ -286,11 +336,62 @@ func (m Model) GetHeight() int {
 
 func (m *Model) SetValue(value string) {
 	m.ta.SetValue(value)
+	m.validate()
+}
+
+// Check if the input can be converted into bytes in the current input mode.
+func (m *Model) validate() {
+	m.inputErr = nil
+	if !m.isMsgLogFilterMode {
+		_, m.inputErr = session.EncodeInput(m.ta.Value(), m.mode)
+	}
+}
+
+// Change the input mode. The mode is shown in the prompt and broadcasted,
+// so the command history only suggests commands sent in the same mode.
+func (m *Model) setMode(mode events.InputMode) tea.Cmd {
+	if mode == m.mode {
+		return nil
+	}
+	m.mode = mode
+	m.inputSuggestion = ""
+	m.ta.Prompt = m.inputPrompt()
+	m.ta.Placeholder = m.placeholder()
+	m.validate()
+
+	inputVal := m.ta.Value()
+	return tea.Batch(
+		func() tea.Msg { return events.InputModeMsg(mode) },
+		func() tea.Msg { return events.PartialTxMsg(inputVal) },
+	)
+}
+
+func (m Model) inputPrompt() string {
+	switch m.mode {
+	case events.EscapeMode:
+		return escapePromt
+	case events.HexMode:
+		return hexPromt
+	default:
+		return inputPromt
+	}
+}
+
+func (m Model) placeholder() string {
+	switch m.mode {
+	case events.EscapeMode:
+		return `Send escaped bytes, e.g. AT\r or \x1b[A...`
+	case events.HexMode:
+		return "Send hex bytes, e.g. 01 0A FF..."
+	default:
+		return "Send a message..."
+	}
 }
 
 func (m *Model) SetDisconnectet() tea.Cmd {
 	m.ta.Reset()
-	m.ta.Prompt = inputPromt
+	m.inputErr = nil
+	m.ta.Prompt = m.inputPrompt()
 	m.ta.Placeholder = "Disconnected"
 	m.ta.Blur()
 	m.inputSuggestion = ""
@@ -302,17 +403,19 @@ func (m *Model) SetDisconnectet() tea.Cmd {
 
 func (m *Model) SetConnected() tea.Cmd {
 	m.ta.Reset()
-	m.ta.Prompt = inputPromt
+	m.inputErr = nil
+	m.ta.Prompt = m.inputPrompt()
 	m.ta.Cursor.Style = styles.CursorStyle
 	m.ta.FocusedStyle.Prompt = styles.FocusedPromtStyle
-	m.ta.Placeholder = "Send a message..."
+	m.ta.Placeholder = m.placeholder()
 	m.inputSuggestion = ""
 	return m.ta.Focus()
 }
 
 func (m *Model) SetConnecting() tea.Cmd {
 	m.ta.Reset()
-	m.ta.Prompt = inputPromt
+	m.inputErr = nil
+	m.ta.Prompt = m.inputPrompt()
 	m.ta.Placeholder = "Connecting..."
 	m.ta.Blur()
 	m.inputSuggestion = ""
@@ -323,8 +426,11 @@ func (m *Model) SetConnecting() tea.Cmd {
 }
 
 func (m *Model) Reset() tea.Cmd {
+	if m.filterOnly {
+		return m.SetFiltering()
+	}
 	m.isMsgLogFilterMode = false
-	m.ta.Prompt = inputPromt
+	m.ta.Prompt = m.inputPrompt()
 	m.ta.Cursor.Style = styles.CursorStyle
 	m.ta.FocusedStyle.Prompt = styles.FocusedPromtStyle
 	filterStringCmd := func() tea.Msg {
@@ -333,9 +439,17 @@ func (m *Model) Reset() tea.Cmd {
 	return tea.Batch(m.SetConnected(), filterStringCmd)
 }
 
+// SetFilterOnly keeps the input in filter mode, e.g. for a file view where
+// nothing can be sent.
+func (m *Model) SetFilterOnly() tea.Cmd {
+	m.filterOnly = true
+	return m.SetFiltering()
+}
+
 func (m *Model) SetFiltering() tea.Cmd {
 	m.isMsgLogFilterMode = true
 	m.ta.Reset()
+	m.inputErr = nil
 	m.ta.Prompt = searchPromt
 	m.ta.Cursor.Style = styles.CursorFilterStyle
 	m.ta.FocusedStyle.Prompt = styles.FocusedSearchPromtStyle
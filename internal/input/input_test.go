@@ -0,0 +1,24 @@
+package input
+
+import (
+	"testing"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Shortcuts of other components are not typed into the input.
+func TestOtherKeysIgnored(t *testing.T) {
+	m := New()
+	for _, msg := range []tea.KeyMsg{
+		{Type: tea.KeyRunes, Runes: []rune("t"), Alt: true}, // new tab
+		{Type: tea.KeyRunes, Runes: []rune("R"), Alt: true}, // record with scrollback
+		{Type: tea.KeyRunes, Runes: []rune("x"), Alt: true}, // hex view
+		{Type: tea.KeyHome},
+		{Type: tea.KeyRunes, Runes: []rune("ok")},
+	} {
+		m, _ = m.Update(msg)
+	}
+	if got := m.ta.Value(); got != "ok" {
+		t.Errorf("input = %q, want %q", got, "ok")
+	}
+}
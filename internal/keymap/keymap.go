@@ -29,18 +29,42 @@ type KeyMap struct {
 	ResetKey         key.Binding `group:"Actions"`
 	SendKey          key.Binding `group:"Actions"`
 	ToggleSessionKey key.Binding `group:"Actions"`
+	SettingsKey      key.Binding `group:"Actions"`
+	HexViewKey       key.Binding `group:"Actions"`
+	InputModeKey     key.Binding `group:"Actions"`
+	FavoritesKey     key.Binding `group:"Actions"`
+	ArmTriggersKey   key.Binding `group:"Actions"`
+	RecordKey        key.Binding `group:"Actions"`
+	RecordAllKey     key.Binding `group:"Actions"`
+	NewTabKey        key.Binding `group:"Actions"`
+	CloseTabKey      key.Binding `group:"Actions"`
+	NextTabKey       key.Binding `group:"Actions"`
+	PrevTabKey       key.Binding `group:"Actions"`
+	SplitKey         key.Binding `group:"Actions"`
+	SwitchPaneKey    key.Binding `group:"Actions"`
+	SplitLayoutKey   key.Binding `group:"Actions"`
+	GrowPaneKey      key.Binding `group:"Actions"`
+	ShrinkPaneKey    key.Binding `group:"Actions"`
 	HelpKey          key.Binding `group:"Actions"`
 	QuitKey          key.Binding `group:"Actions"`
 	CloseKey         key.Binding `group:"Actions"`
 	AutoCompleteKey  key.Binding `group:"Actions"`
 	FilterMsgLogKey  key.Binding `group:"Actions"`
 	DebugKey         key.Binding `group:"Actions"`
+
+	// Replay Group
+	ReplayPauseKey   key.Binding `group:"Replay"`
+	ReplayStepKey    key.Binding `group:"Replay"`
+	ReplayFasterKey  key.Binding `group:"Replay"`
+	ReplaySlowerKey  key.Binding `group:"Replay"`
+	ReplayBackKey    key.Binding `group:"Replay"`
+	ReplayForwardKey key.Binding `group:"Replay"`
 }
 
 // ShortHelp returns keybindings to be shown in the mini help view. It's part
 // of the key.Map interface.
 func (k KeyMap) ShortHelp() []key.Binding {
-	return []key.Binding{k.QuitKey, k.HelpKey, k.ToggleHistKey, k.OpenEditorKey, k.ToggleSessionKey}
+	return []key.Binding{k.QuitKey, k.HelpKey, k.ToggleHistKey, k.OpenEditorKey, k.ToggleSessionKey, k.SettingsKey}
 }
 
 // FullHelp returns keybindings for the expanded help view. It's part of the
@@ -50,6 +74,7 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	var (
 		navigation []key.Binding
 		actions    []key.Binding
+		replay     []key.Binding
 		other      []key.Binding // For keys without a tag
 	)
 
@@ -70,6 +95,8 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 				navigation = append(navigation, binding)
 			case "Actions":
 				actions = append(actions, binding)
+			case "Replay":
+				replay = append(replay, binding)
 			default:
 				other = append(other, binding)
 			}
@@ -81,6 +108,7 @@ func (k KeyMap) FullHelp() [][]key.Binding {
 	return [][]key.Binding{
 		navigation,
 		actions,
+		replay,
 		other,
 	}
 }
@@ -167,6 +195,70 @@ var Default = KeyMap{
 		key.WithKeys("ctrl+x"),
 		key.WithHelp("ctrl+x", "open/close port"),
 	),
+	SettingsKey: key.NewBinding(
+		key.WithKeys("ctrl+s"),
+		key.WithHelp("ctrl+s", "port settings"),
+	),
+	HexViewKey: key.NewBinding(
+		key.WithKeys("alt+x"),
+		key.WithHelp("alt+x", "toggle hex view"),
+	),
+	InputModeKey: key.NewBinding(
+		key.WithKeys("alt+i"),
+		key.WithHelp("alt+i", "text/escape/hex input"),
+	),
+	FavoritesKey: key.NewBinding(
+		key.WithKeys("alt+f"),
+		key.WithHelp("alt+f", "favorites bar"),
+	),
+	ArmTriggersKey: key.NewBinding(
+		key.WithKeys("alt+a"),
+		key.WithHelp("alt+a", "arm/disarm triggers"),
+	),
+	RecordKey: key.NewBinding(
+		key.WithKeys("alt+r"),
+		key.WithHelp("alt+r", "start/stop recording"),
+	),
+	RecordAllKey: key.NewBinding(
+		key.WithKeys("alt+R"),
+		key.WithHelp("alt+R", "record with scrollback"),
+	),
+	NewTabKey: key.NewBinding(
+		key.WithKeys("alt+t"),
+		key.WithHelp("alt+t", "new tab"),
+	),
+	CloseTabKey: key.NewBinding(
+		key.WithKeys("alt+w"),
+		key.WithHelp("alt+w", "close tab"),
+	),
+	NextTabKey: key.NewBinding(
+		key.WithKeys("alt+n"),
+		key.WithHelp("alt+n", "next tab"),
+	),
+	PrevTabKey: key.NewBinding(
+		key.WithKeys("alt+p"),
+		key.WithHelp("alt+p", "previous tab"),
+	),
+	SplitKey: key.NewBinding(
+		key.WithKeys("alt+s"),
+		key.WithHelp("alt+s", "split view"),
+	),
+	SwitchPaneKey: key.NewBinding(
+		key.WithKeys("alt+o"),
+		key.WithHelp("alt+o", "send to other pane"),
+	),
+	SplitLayoutKey: key.NewBinding(
+		key.WithKeys("alt+v"),
+		key.WithHelp("alt+v", "panes side by side/stacked"),
+	),
+	GrowPaneKey: key.NewBinding(
+		key.WithKeys("alt+="),
+		key.WithHelp("alt+=", "grow pane"),
+	),
+	ShrinkPaneKey: key.NewBinding(
+		key.WithKeys("alt+-"),
+		key.WithHelp("alt+-", "shrink pane"),
+	),
 	AutoCompleteKey: key.NewBinding(
 		key.WithKeys("tab", "right"),
 		key.WithHelp("tab/→", "use auto suggestion"),
@@ -179,4 +271,28 @@ var Default = KeyMap{
 		key.WithKeys("ctrl+p"),
 		key.WithHelp("ctrl+p", "debug keybinding"),
 	),
+	ReplayPauseKey: key.NewBinding(
+		key.WithKeys("alt+ "),
+		key.WithHelp("alt+space", "pause/resume replay"),
+	),
+	ReplayStepKey: key.NewBinding(
+		key.WithKeys("alt+."),
+		key.WithHelp("alt+.", "replay next message"),
+	),
+	ReplayFasterKey: key.NewBinding(
+		key.WithKeys("alt+>"),
+		key.WithHelp("alt+>", "replay faster"),
+	),
+	ReplaySlowerKey: key.NewBinding(
+		key.WithKeys("alt+<"),
+		key.WithHelp("alt+<", "replay slower"),
+	),
+	ReplayBackKey: key.NewBinding(
+		key.WithKeys("alt+left"),
+		key.WithHelp("alt+←", "replay back 10 s"),
+	),
+	ReplayForwardKey: key.NewBinding(
+		key.WithKeys("alt+right"),
+		key.WithHelp("alt+→", "replay forward 10 s"),
+	),
 }
@@ -2,19 +2,30 @@ package internal
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"path/filepath"
+	"strconv"
 	"time"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	"github.com/charmbracelet/bubbles/spinner"
 	tea "github.com/charmbracelet/bubbletea"
 	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/session"
 )
 
+// namedCloser is a log file, like an *os.File.
+type namedCloser interface {
+	io.Closer
+	Name() string
+}
+
 // Returns a function to close a specific logger and check for errors.
-func CloseLogger(f *os.File, closemsg string) func() {
+func CloseLogger(f namedCloser, closemsg string) func() {
 	return func() {
 		if err := f.Close(); err != nil {
 			fmt.Printf("Warning: Failed to close log file %s: %v\n", f.Name(), err)
@@ -48,13 +59,61 @@ func DbgLogMsgType(msg any) {
 }
 
 // Open (or create if no exist) a log file for serial logging.
-// Returns a close function and the serial logger.
-func StartSerialLogger(logDirPath string) (*log.Logger, func()) {
+// Returns the log file and a close function. The log file is split into
+// segments by the rotation config, each segment starts with the header.
+func StartSerialLogger(logDirPath string, rotation logrotate.Config, header func(time.Time) string,
+) (*logrotate.Writer, func()) {
 	// Generate the unique file name: <date>-<time>-teamterm.log
 	// Format: YYYYMMDD-HHMMSS-teamterm.log
 	fileName := "teaterm-" + time.Now().Format("2006-01-02T15:04:05") + ".log"
 	// fileName := "teaterm.log"
-	fullPath := filepath.Join(logDirPath, fileName)
+	return startSerialLogger(filepath.Join(logDirPath, fileName), rotation, header)
+}
+
+// Open the serial log of a tab opened in the TUI. The port is part of the
+// file name, tabs may be opened within the same second.
+func startTabLogger(logDirPath string, rotation logrotate.Config, header func(time.Time) string, port string,
+) (*logrotate.Writer, func()) {
+	return startSerialLogger(tabLogPath(logDirPath, port), rotation, header)
+}
+
+// tabLogPath returns the path of a new serial log of a tab. Logs of the port
+// started within the same second get a sequence number, like
+// teaterm-<date>T<time>-<port>-2.log.
+func tabLogPath(logDirPath string, port string) string {
+	name := "teaterm-" + time.Now().Format("2006-01-02T15:04:05") + "-" + sanitizeFileName(port)
+	path := filepath.Join(logDirPath, name+".log")
+	for seq := 2; logExists(path); seq++ {
+		path = filepath.Join(logDirPath, name+"-"+strconv.Itoa(seq)+".log")
+	}
+	return path
+}
+
+// logExists reports whether the serial log exists, it may be compressed
+// after a rotation.
+func logExists(path string) bool {
+	for _, p := range []string{path, path + ".gz"} {
+		if _, err := os.Stat(p); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// SerialLogHeader returns the header of the serial log segments of a session
+// with the settings.
+func SerialLogHeader(format msglog.LogFormat, settings session.Settings) func(time.Time) string {
+	return func(t time.Time) string {
+		text := fmt.Sprintf("teaterm %s log of %s %s, eol %s, framing %s, started %s", Version, settings.Port,
+			session.FormatMode(settings.Mode), session.FormatLineEnding(settings.LineEnding), settings.Framing,
+			t.Format(time.DateTime))
+		return msglog.LogHeader(format, settings.Port, t, text)
+	}
+}
+
+func startSerialLogger(fullPath string, rotation logrotate.Config, header func(time.Time) string,
+) (*logrotate.Writer, func()) {
+	logDirPath := filepath.Dir(fullPath)
 
 	// Check if the directory exists.
 	dirInfo, err := os.Stat(logDirPath)
@@ -69,15 +128,36 @@ func StartSerialLogger(logDirPath string) (*log.Logger, func()) {
 		os.Exit(1)
 	}
 
-	f, err := os.OpenFile(fullPath, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	f, closeLog, err := openSerialLog(fullPath, rotation, header)
 	if err != nil {
 		fmt.Printf("fatal: Failed to open log file %s: %v\n", fullPath, err)
 		os.Exit(1)
 	}
+	return f, closeLog
+}
 
-	log.Println("Create serial logger at " + fullPath)
+// openLog is a serial log opened in the TUI with the function closing it.
+type openLog struct {
+	f     *logrotate.Writer
+	close func()
+}
+
+// openSerialLog opens a serial log file. The close function prints the name
+// of the last segment.
+func openSerialLog(fullPath string, rotation logrotate.Config, header func(time.Time) string,
+) (*logrotate.Writer, func(), error) {
+	f, err := logrotate.Open(fullPath, rotation)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := f.SetHeader(header); err != nil {
+		f.Close()
+		return nil, nil, err
+	}
 
-	serialLogger := log.New(f, "", 0) // flags could be log.Ldate|log.Ltime|log.Lmicroseconds
+	log.Println("Create serial logger at " + fullPath)
 
-	return serialLogger, CloseLogger(f, "Logfile created under "+fullPath)
+	return f, func() {
+		CloseLogger(f, "Logfile created under "+f.Name())()
+	}, nil
 }
@@ -0,0 +1,291 @@
+// Package logrotate writes the serial log into segments of limited size or
+// duration. The first segment has the path given, the following ones get a
+// sequence number, like teaterm-2026-10-16T10:00:00.2.log. Finished segments
+// can be compressed with gzip, the oldest ones are removed beyond a retention
+// count.
+package logrotate
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Config tells when a segment is finished and what happens with finished
+// segments. The zero value writes a single file.
+type Config struct {
+	MaxSize  int64         // bytes per segment, 0 for no limit
+	Interval time.Duration // time per segment, 0 for no limit
+	Keep     int           // finished segments kept, 0 keeps all
+	Gzip     bool          // compress finished segments
+}
+
+// Writer writes the log into the current segment and starts a new one when
+// the segment is full. Each write goes into one segment, so the lines of the
+// log are never split.
+type Writer struct {
+	path   string // path of the first segment
+	config Config
+	now    func() time.Time
+
+	mu         sync.Mutex // guards the fields below
+	f          *os.File
+	seq        int // number of the current segment, starting at 1
+	size       int64
+	headerSize int64 // size of the header of the current segment
+	started    time.Time
+	header     func(t time.Time) string
+	closed     bool
+	timer      *time.Timer // finishes an idle segment when its time is over, nil without interval
+
+	// finished segments are compressed and removed in the background
+	finished []string      // finished segments not yet handled, guarded by mu
+	wake     chan struct{} // tells the cleanup about finished segments
+	done     chan struct{}
+	kept     []string // finished segments, oldest first
+}
+
+// Open creates or appends to the first segment at path.
+func Open(path string, config Config) (*Writer, error) {
+	w := &Writer{
+		path:   path,
+		config: config,
+		now:    time.Now,
+		seq:    1,
+		wake:   make(chan struct{}, 1),
+		done:   make(chan struct{}),
+	}
+	if err := w.open(); err != nil {
+		return nil, err
+	}
+	go w.cleanup()
+	if config.Interval > 0 {
+		w.timer = time.AfterFunc(config.Interval, w.rotateIdle)
+	}
+	return w, nil
+}
+
+// segmentPath returns the path of the segment with the number.
+func (w *Writer) segmentPath(seq int) string {
+	if seq == 1 {
+		return w.path
+	}
+	ext := filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "." + strconv.Itoa(seq) + ext
+}
+
+// open opens the current segment.
+func (w *Writer) open() error {
+	f, err := os.OpenFile(w.segmentPath(w.seq), os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o666)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return err
+	}
+	w.f = f
+	w.size = info.Size()
+	w.headerSize = 0
+	w.started = w.now()
+	return nil
+}
+
+// SetHeader sets the function returning the header written at the start of
+// every segment. The header is written at once if the current segment is
+// still empty.
+func (w *Writer) SetHeader(header func(t time.Time) string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.header = header
+	if w.size == 0 {
+		return w.writeHeader()
+	}
+	return nil
+}
+
+func (w *Writer) writeHeader() error {
+	if w.header == nil {
+		return nil
+	}
+	n, err := io.WriteString(w.f, w.header(w.started)+"\n")
+	w.size += int64(n)
+	w.headerSize = int64(n)
+	return err
+}
+
+// Write writes p into the current segment. A new segment is started before,
+// if p does not fit into the current one or its time is over.
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.full(len(p)) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+	n, err := w.f.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// full reports whether the current segment must be finished before n bytes
+// are written. A segment gets at least one write besides the header.
+func (w *Writer) full(n int) bool {
+	if w.size <= w.headerSize {
+		return false
+	}
+	if w.config.Interval > 0 && w.now().Sub(w.started) >= w.config.Interval {
+		return true
+	}
+	return w.config.MaxSize > 0 && w.size+int64(n) > w.config.MaxSize
+}
+
+// rotate finishes the current segment and starts the next one.
+func (w *Writer) rotate() error {
+	if err := w.f.Close(); err != nil {
+		return err
+	}
+	w.finished = append(w.finished, w.f.Name())
+	select {
+	case w.wake <- struct{}{}:
+	default: // the cleanup is woken already
+	}
+	w.seq++
+	if err := w.open(); err != nil {
+		return err
+	}
+	if w.timer != nil {
+		w.timer.Reset(w.config.Interval)
+	}
+	return w.writeHeader()
+}
+
+// rotateIdle finishes the current segment when its time is over, even if
+// nothing is written. An empty segment is kept for the next interval.
+func (w *Writer) rotateIdle() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return
+	}
+	if w.full(0) {
+		w.rotate() // a failure is returned by the next write
+		return
+	}
+	left := w.config.Interval - w.now().Sub(w.started)
+	if left <= 0 {
+		left = w.config.Interval
+	}
+	w.timer.Reset(left)
+}
+
+// cleanup compresses the finished segments and removes the ones beyond the
+// retention count, until the writer is closed.
+func (w *Writer) cleanup() {
+	defer close(w.done)
+	for range w.wake {
+		w.mu.Lock()
+		finished := w.finished
+		w.finished = nil
+		w.mu.Unlock()
+
+		for _, path := range finished {
+			if w.config.Gzip {
+				if gzPath, err := compress(path); err == nil {
+					path = gzPath
+				}
+			}
+			w.kept = append(w.kept, path)
+			for w.config.Keep > 0 && len(w.kept) > w.config.Keep {
+				os.Remove(w.kept[0])
+				w.kept = w.kept[1:]
+			}
+		}
+	}
+}
+
+// compress replaces the file by a gzip file and returns its path.
+func compress(path string) (string, error) {
+	in, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer in.Close()
+
+	gzPath := path + ".gz"
+	out, err := os.Create(gzPath)
+	if err != nil {
+		return "", err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = filepath.Base(path)
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(gzPath)
+		return "", err
+	}
+	return gzPath, os.Remove(path)
+}
+
+// Name returns the path of the current segment.
+func (w *Writer) Name() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.f.Name()
+}
+
+// Size returns the size of the current segment.
+func (w *Writer) Size() int64 {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.size
+}
+
+// Close closes the current segment and waits until the finished segments are
+// compressed. The current segment is not compressed. Closing a closed writer
+// does nothing.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	if w.closed {
+		w.mu.Unlock()
+		return nil
+	}
+	w.closed = true
+	if w.timer != nil {
+		w.timer.Stop()
+	}
+	err := w.f.Close()
+	close(w.wake)
+	w.mu.Unlock()
+	<-w.done
+	return err
+}
+
+// ParseSize parses a size in bytes with an optional unit K, M or G, like
+// 100M. Units are powers of 1024.
+func ParseSize(s string) (int64, error) {
+	units := map[string]int64{"": 1, "K": 1 << 10, "M": 1 << 20, "G": 1 << 30}
+	num := strings.TrimRight(s, "KMGkmgBb")
+	unit := strings.ToUpper(strings.TrimSuffix(strings.TrimSuffix(s[len(num):], "B"), "b"))
+	factor, ok := units[unit]
+	n, err := strconv.ParseInt(num, 10, 64)
+	if !ok || err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q, use bytes or a number with K, M or G", s)
+	}
+	return n * factor, nil
+}
@@ -0,0 +1,165 @@
+package logrotate
+
+import (
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestParseSize(t *testing.T) {
+	tests := []struct {
+		s    string
+		want int64
+		ok   bool
+	}{
+		{"4096", 4096, true},
+		{"100M", 100 << 20, true},
+		{"1G", 1 << 30, true},
+		{"512kb", 512 << 10, true},
+		{"10MB", 10 << 20, true},
+		{"M", 0, false},
+		{"1T", 0, false},
+		{"-1", 0, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseSize(tt.s)
+		if (err == nil) != tt.ok || got != tt.want {
+			t.Errorf("ParseSize(%q) = %d, %v", tt.s, got, err)
+		}
+	}
+}
+
+// readFile returns the content of a file, gzip files are decompressed.
+func readFile(t *testing.T, path string) string {
+	t.Helper()
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	r := io.Reader(f)
+	if strings.HasSuffix(path, ".gz") {
+		if r, err = gzip.NewReader(f); err != nil {
+			t.Fatal(err)
+		}
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestRotateBySize(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(filepath.Join(dir, "serial.log"), Config{MaxSize: 23, Keep: 2, Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.SetHeader(func(time.Time) string { return "# header" })
+
+	for _, line := range []string{"line 1\n", "line 2\n", "line 3\n", "line 4\n", "line 5\n", "line 6\n", "line 7\n"} {
+		if _, err := w.Write([]byte(line)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if got, want := filepath.Base(w.Name()), "serial.4.log"; got != want {
+		t.Errorf("current segment = %s, want %s", got, want)
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	// the first segment is removed, two finished ones are kept
+	want := map[string]string{
+		"serial.2.log.gz": "# header\nline 3\nline 4\n",
+		"serial.3.log.gz": "# header\nline 5\nline 6\n",
+		"serial.4.log":    "# header\nline 7\n",
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != len(want) {
+		t.Errorf("got %d files, want %d", len(entries), len(want))
+	}
+	for name, content := range want {
+		if got := readFile(t, filepath.Join(dir, name)); got != content {
+			t.Errorf("%s = %q, want %q", name, got, content)
+		}
+	}
+}
+
+func TestRotateByTime(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(filepath.Join(dir, "serial.log"), Config{Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2026, 10, 16, 10, 0, 0, 0, time.UTC)
+	w.now = func() time.Time { return now }
+	w.started = now
+
+	w.Write([]byte("line 1\n"))
+	now = now.Add(30 * time.Minute)
+	w.Write([]byte("line 2\n"))
+	now = now.Add(30 * time.Minute)
+	w.Write([]byte("line 3\n"))
+	w.Close()
+
+	if got, want := readFile(t, filepath.Join(dir, "serial.log")), "line 1\nline 2\n"; got != want {
+		t.Errorf("first segment = %q, want %q", got, want)
+	}
+	if got, want := readFile(t, filepath.Join(dir, "serial.2.log")), "line 3\n"; got != want {
+		t.Errorf("second segment = %q, want %q", got, want)
+	}
+}
+
+func TestRotateMany(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(filepath.Join(dir, "serial.log"), Config{MaxSize: 7, Keep: 3, Gzip: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// more segments are finished than the cleanup can keep up with
+	for range 100 {
+		if _, err := w.Write([]byte("line x\n")); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, _ := os.ReadDir(dir)
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	if want := "serial.100.log serial.97.log.gz serial.98.log.gz serial.99.log.gz"; strings.Join(names, " ") != want {
+		t.Errorf("files = %v, want %s", names, want)
+	}
+}
+
+// An idle segment is finished when its time is over.
+func TestRotateIdle(t *testing.T) {
+	dir := t.TempDir()
+	w, err := Open(filepath.Join(dir, "serial.log"), Config{Interval: 50 * time.Millisecond})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer w.Close()
+
+	w.Write([]byte("line 1\n"))
+	for deadline := time.Now().Add(2 * time.Second); filepath.Base(w.Name()) != "serial.2.log"; {
+		if time.Now().After(deadline) {
+			t.Fatal("idle segment not finished")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if got, want := readFile(t, filepath.Join(dir, "serial.log")), "line 1\n"; got != want {
+		t.Errorf("first segment = %q, want %q", got, want)
+	}
+}
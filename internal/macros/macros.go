@@ -0,0 +1,301 @@
+// Package macros sends predefined commands from the config file. Each macro is
+// bound to a key like f1 and is listed in the favorites bar, where it can be
+// started with a mouse click as well.
+package macros
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/viewport"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+// Macro is a named list of commands as defined in the config file.
+type Macro struct {
+	Name     string    `toml:"name"`
+	Key      string    `toml:"key"`
+	Commands []Command `toml:"commands"`
+}
+
+// Command is a single command of a macro. It is sent after the delay.
+type Command struct {
+	Send  string        `toml:"send"`
+	Mode  string        `toml:"mode"` // text (default), esc or hex
+	Delay time.Duration `toml:"delay"`
+}
+
+// Sent after the delay of the current command of a macro run.
+type stepMsg struct {
+	run int
+}
+
+type Model struct {
+	macros   []Macro
+	bindings []key.Binding
+	width    int
+	active   bool
+	// visible mirrors the root model's showMacros, macros are only clicked
+	// while the favorites bar is shown.
+	visible bool
+	// running is the index of the running macro, -1 if no macro runs.
+	running int
+	step    int
+	// run identifies the current macro run, pending steps of stopped
+	// runs are dropped.
+	run int
+	// waiting is set while the current command is written to the port.
+	waiting bool
+}
+
+// Validate checks the macro definitions of the config file.
+func Validate(macros []Macro) error {
+	keys := make(map[string]string)
+	for _, m := range macros {
+		if m.Name == "" {
+			return fmt.Errorf("macro without name")
+		}
+		if m.Key != "" {
+			if other, ok := keys[m.Key]; ok {
+				return fmt.Errorf("macro %q: key %s is already used by macro %q", m.Name, m.Key, other)
+			}
+			if used := usedKey(m.Key); used != "" {
+				return fmt.Errorf("macro %q: key %s is already used to %s", m.Name, m.Key, used)
+			}
+			keys[m.Key] = m.Name
+		}
+		if len(m.Commands) == 0 {
+			return fmt.Errorf("macro %q has no commands", m.Name)
+		}
+		for _, c := range m.Commands {
+			mode, err := events.ParseInputMode(c.Mode)
+			if err != nil {
+				return fmt.Errorf("macro %q: %v", m.Name, err)
+			}
+			if _, err := session.EncodeInput(c.Send, mode); err != nil {
+				return fmt.Errorf("macro %q: %q: %v", m.Name, c.Send, err)
+			}
+			if c.Delay < 0 {
+				return fmt.Errorf("macro %q: invalid delay %s", m.Name, c.Delay)
+			}
+		}
+	}
+	return nil
+}
+
+// usedKey returns the help text of the default key binding using the key.
+func usedKey(k string) string {
+	for _, group := range keymap.Default.FullHelp() {
+		for _, binding := range group {
+			for _, bk := range binding.Keys() {
+				if bk == k {
+					return binding.Help().Desc
+				}
+			}
+		}
+	}
+	return ""
+}
+
+// New creates the model for the given macros, that must be validated before.
+func New(macros []Macro) (m Model) {
+	m.macros = macros
+	m.running = -1
+	// the session starts connected
+	m.active = true
+	for _, macro := range macros {
+		var binding key.Binding
+		if macro.Key != "" {
+			binding = key.NewBinding(key.WithKeys(macro.Key), key.WithHelp(macro.Key, macro.Name))
+		}
+		m.bindings = append(m.bindings, binding)
+	}
+	return m
+}
+
+// Matches reports whether the key starts a macro.
+func (m Model) Matches(msg tea.KeyMsg) bool {
+	for _, binding := range m.bindings {
+		if key.Matches(msg, binding) {
+			return true
+		}
+	}
+	return false
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case events.ConnectionStatusMsg:
+		m.active = msg.Status == events.Connected
+		if !m.active {
+			m.stop()
+		}
+		return m, nil
+	}
+
+	// do not handle any other events during inactive state
+	if !m.active {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if key.Matches(msg, keymap.Default.ResetKey) && m.running >= 0 {
+			name := m.Stop()
+			return m, func() tea.Msg {
+				return events.InfoMsg(fmt.Sprintf("Macro %q stopped", name))
+			}
+		}
+		for i, binding := range m.bindings {
+			if key.Matches(msg, binding) {
+				return m, m.start(i)
+			}
+		}
+
+	case tea.MouseMsg:
+		if !m.visible || msg.Button != tea.MouseButtonLeft || msg.Action != tea.MouseActionRelease {
+			return m, nil
+		}
+		for i := range m.macros {
+			if zone.Get(zoneID(i)).InBounds(msg) {
+				return m, m.start(i)
+			}
+		}
+
+	case stepMsg:
+		if msg.run != m.run || m.running < 0 {
+			return m, nil
+		}
+		m.waiting = true
+		return m, SendCmdExecutedMsg(m.macros[m.running].Commands[m.step])
+
+	case events.SerialTxMsg:
+		// the command is written, continue with the next one
+		if m.waiting {
+			m.waiting = false
+			m.step++
+			if m.step == len(m.macros[m.running].Commands) {
+				m.stop()
+				return m, nil
+			}
+			return m, m.stepCmd()
+		}
+
+	case events.ErrMsg:
+		if m.waiting {
+			m.stop()
+		}
+	}
+
+	return m, nil
+}
+
+// start runs the macro, a running macro is stopped.
+func (m *Model) start(i int) tea.Cmd {
+	m.stop()
+	m.running = i
+	return m.stepCmd()
+}
+
+// Stop stops the running macro, e.g. when another tab is shown, and returns
+// its name. It returns "" if no macro runs.
+func (m *Model) Stop() string {
+	if m.running < 0 {
+		return ""
+	}
+	name := m.macros[m.running].Name
+	m.stop()
+	return name
+}
+
+func (m *Model) stop() {
+	m.running = -1
+	m.step = 0
+	m.waiting = false
+	m.run++
+}
+
+// stepCmd sends the current command after its delay.
+func (m Model) stepCmd() tea.Cmd {
+	msg := stepMsg{run: m.run}
+	delay := m.macros[m.running].Commands[m.step].Delay
+	if delay == 0 {
+		return func() tea.Msg { return msg }
+	}
+	return tea.Tick(delay, func(time.Time) tea.Msg { return msg })
+}
+
+// Returns a Tea command that transmits the given macro command
+// (events.SendMsg). Macro commands are not added to the command history.
+func SendCmdExecutedMsg(c Command) tea.Cmd {
+	mode, _ := events.ParseInputMode(c.Mode)
+	return func() tea.Msg {
+		return events.SendMsg{Data: c.Send, Mode: mode, Automated: true}
+	}
+}
+
+func zoneID(i int) string {
+	return "macro" + strconv.Itoa(i)
+}
+
+func (m *Model) SetWidth(w int) {
+	m.width = w
+}
+
+// SetVisible keeps the model in sync with the visibility of the favorites bar.
+func (m *Model) SetVisible(visible bool) {
+	m.visible = visible
+}
+
+func (m Model) GetHeight() int {
+	return lipgloss.Height(m.View())
+}
+
+// View renders the favorites bar. The macros are wrapped into as many lines
+// as needed.
+func (m Model) View() string {
+	borderWidth, _ := styles.BorderStyle.GetFrameSize()
+	width := max(m.width-borderWidth, 1)
+
+	var lines []string
+	var line string
+	for i, macro := range m.macros {
+		var entry string
+		switch {
+		case i == m.running:
+			entry = styles.SelectedCmdStyle.Render(strings.TrimSpace(macro.Key + " " + macro.Name))
+		case macro.Key != "":
+			entry = styles.HelpKey.Render(macro.Key) + " " + styles.HelpDesc.Render(macro.Name)
+		default:
+			entry = styles.HelpDesc.Render(macro.Name)
+		}
+		entry = zone.Mark(zoneID(i), entry)
+
+		if line != "" && lipgloss.Width(line)+2+lipgloss.Width(entry) > width {
+			lines = append(lines, line)
+			line = ""
+		}
+		if line != "" {
+			line += "  "
+		}
+		line += entry
+	}
+	lines = append(lines, line)
+
+	if len(m.macros) == 0 {
+		lines = []string{styles.FocusedPlaceholderStyle.Render("no macros defined in the config file")}
+	}
+
+	vp := viewport.New(width, len(lines))
+	vp.SetContent(strings.Join(lines, "\n"))
+	return styles.AddBorder(vp, "Favorites", "", false)
+}
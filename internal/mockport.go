@@ -200,6 +200,11 @@ func (m *mockPort) Write(p []byte) (n int, err error) {
 	return len(p), nil
 }
 
+// SetMode accepts every serial mode, the mock has no line settings.
+func (m *mockPort) SetMode(mode *serial.Mode) error {
+	return nil
+}
+
 // Close stops the mock port's internal goroutine.
 func (m *mockPort) Close() error {
 	log.Println("MOCK PORT: Closing")
@@ -208,10 +213,7 @@ func (m *mockPort) Close() error {
 }
 
 // OpenFakePort is a convenient wrapper for creating the mock.
-func OpenFakePort() (io.ReadWriteCloser, serial.Mode) {
-	mode := serial.Mode{
-		BaudRate: 115200, // TODO make configurable
-	}
-
-	return NewMockPort(), mode
+// The mock ignores the serial line settings.
+func OpenFakePort() io.ReadWriteCloser {
+	return NewMockPort()
 }
@@ -0,0 +1,46 @@
+package msglog
+
+import (
+	"fmt"
+	"strings"
+)
+
+const hexDumpRowLen = 16
+
+// hexDump formats data like hexdump -C. Each row shows the offset, up to 16
+// bytes in hex and the bytes as ASCII, non printable bytes are shown as dot.
+// Empty data results in a single row without bytes.
+func hexDump(data string) []string {
+	var rows []string
+
+	for offset := 0; offset == 0 || offset < len(data); offset += hexDumpRowLen {
+		end := min(offset+hexDumpRowLen, len(data))
+
+		var row strings.Builder
+		row.WriteString(fmt.Sprintf("%08x  ", offset))
+		for i := offset; i < offset+hexDumpRowLen; i++ {
+			if i < end {
+				row.WriteString(fmt.Sprintf("%02x ", data[i]))
+			} else {
+				row.WriteString("   ")
+			}
+			if i == offset+hexDumpRowLen/2-1 {
+				row.WriteByte(' ')
+			}
+		}
+
+		row.WriteString(" |")
+		for i := offset; i < end; i++ {
+			if data[i] >= 32 && data[i] < 127 {
+				row.WriteByte(data[i])
+			} else {
+				row.WriteByte('.')
+			}
+		}
+		row.WriteByte('|')
+
+		rows = append(rows, row.String())
+	}
+
+	return rows
+}
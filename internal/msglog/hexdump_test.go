@@ -0,0 +1,31 @@
+package msglog
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestHexDump(t *testing.T) {
+	tests := []struct {
+		data string
+		want []string
+	}{
+		{"", []string{
+			"00000000                                                    ||",
+		}},
+		{"hello\r\n", []string{
+			"00000000  68 65 6c 6c 6f 0d 0a                              |hello..|",
+		}},
+		{"0123456789abcdef\x00\xff", []string{
+			"00000000  30 31 32 33 34 35 36 37  38 39 61 62 63 64 65 66  |0123456789abcdef|",
+			"00000010  00 ff                                             |..|",
+		}},
+	}
+
+	for _, tt := range tests {
+		got := hexDump(tt.data)
+		if strings.Join(got, "\n") != strings.Join(tt.want, "\n") {
+			t.Errorf("hexDump(%q) =\n%s\nwant\n%s", tt.data, strings.Join(got, "\n"), strings.Join(tt.want, "\n"))
+		}
+	}
+}
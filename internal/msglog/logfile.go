@@ -0,0 +1,252 @@
+package msglog
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mahlburgc/teaterm/internal/handover"
+)
+
+const (
+	// the offset of every fileIndexStep-th line of a file is kept in its index
+	fileIndexStep = 256
+	// time between the progress signals while a file is indexed
+	fileProgressInterval = 100 * time.Millisecond
+)
+
+// logFile is a file shown in the message log. Its lines are read when they
+// are shown, only the offsets of every fileIndexStep-th line are kept, so
+// files of any size can be viewed. The index is built in the background, the
+// lines indexed so far can be shown meanwhile.
+type logFile struct {
+	path string
+	f    *os.File
+
+	mu    sync.Mutex // guards the fields below, they grow while the file is indexed
+	index []int64    // offsets of the lines 0, fileIndexStep, 2*fileIndexStep...
+	lines int
+	err   error // error of the indexing
+
+	progress chan struct{} // signals newly indexed lines
+	indexed  chan struct{} // closed when the index is complete
+	waits    handover.Latest[struct{}]
+}
+
+// openLogFile opens the file and starts to index its lines.
+func openLogFile(path string) (*logFile, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	lf := &logFile{
+		path:     path,
+		f:        f,
+		progress: make(chan struct{}, 1),
+		indexed:  make(chan struct{}),
+	}
+	go lf.buildIndex()
+	return lf, nil
+}
+
+// buildIndex indexes the lines of the file until its end or until the file is
+// closed.
+func (lf *logFile) buildIndex() {
+	defer close(lf.indexed)
+	buf := make([]byte, 1<<20)
+	var offset int64
+	lineStart := true
+	notified := time.Now()
+	for {
+		n, err := lf.f.Read(buf)
+		b := buf[:n]
+		lf.mu.Lock()
+		for len(b) > 0 {
+			if lineStart {
+				if lf.lines%fileIndexStep == 0 {
+					lf.index = append(lf.index, offset)
+				}
+				lf.lines++
+				lineStart = false
+			}
+			i := bytes.IndexByte(b, '\n')
+			if i < 0 {
+				offset += int64(len(b))
+				break
+			}
+			offset += int64(i + 1)
+			b = b[i+1:]
+			lineStart = true
+		}
+		if err != nil && !errors.Is(err, io.EOF) && !errors.Is(err, os.ErrClosed) {
+			lf.err = err
+		}
+		lf.mu.Unlock()
+
+		if err != nil {
+			return
+		}
+		if time.Since(notified) >= fileProgressInterval {
+			notified = time.Now()
+			select {
+			case lf.progress <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// lineCount returns the number of lines indexed so far.
+func (lf *logFile) lineCount() int {
+	lf.mu.Lock()
+	defer lf.mu.Unlock()
+	return lf.lines
+}
+
+// done reports whether the index is complete and the error of the indexing.
+func (lf *logFile) done() (bool, error) {
+	select {
+	case <-lf.indexed:
+		lf.mu.Lock()
+		defer lf.mu.Unlock()
+		return true, lf.err
+	default:
+		return false, nil
+	}
+}
+
+// waitProgress blocks until more lines are indexed or the index is
+// complete. It returns false, if a newer call waits instead, e.g. the one of
+// the program restarted after the editor.
+func (lf *logFile) waitProgress() bool {
+	_, ok := lf.waits.Wait(func() struct{} {
+		select {
+		case <-lf.progress:
+		case <-lf.indexed:
+		}
+		return struct{}{}
+	})
+	return ok
+}
+
+// section returns a reader of the file from the offset to its end.
+func (lf *logFile) section(offset int64) *io.SectionReader {
+	return io.NewSectionReader(lf.f, offset, math.MaxInt64-offset)
+}
+
+// reader returns a reader of the file starting at the line with the number,
+// rounded down to an indexed line. It returns the number of the first line.
+func (lf *logFile) reader(num int) (*bufio.Reader, int) {
+	lf.mu.Lock()
+	block, offset := 0, int64(0)
+	if i := min(num/fileIndexStep, len(lf.index)-1); i >= 0 {
+		block, offset = i, lf.index[i]
+	}
+	lf.mu.Unlock()
+	return bufio.NewReader(lf.section(offset)), block * fileIndexStep
+}
+
+// readLines returns the lines with the numbers, which must be ascending.
+func (lf *logFile) readLines(nums []int) ([]string, error) {
+	var r *bufio.Reader
+	next := 0 // number of the next line read from r
+	lines := make([]string, 0, len(nums))
+	for _, num := range nums {
+		if r == nil || num < next || num-next > fileIndexStep {
+			r, next = lf.reader(num)
+		}
+		for ; next < num; next++ {
+			if err := skipLine(r); err != nil {
+				return lines, err
+			}
+		}
+		line, err := readLine(r)
+		if err != nil {
+			return lines, err
+		}
+		lines = append(lines, line)
+		next++
+	}
+	return lines, nil
+}
+
+// filter returns the numbers of all lines the match function accepts. The
+// whole file is searched, even if it is not indexed yet. The search stops
+// early if the context is canceled.
+func (lf *logFile) filter(ctx context.Context, match func(line string) bool) ([]int, error) {
+	r := bufio.NewReaderSize(lf.section(0), 1<<20)
+	matches := []int{}
+	for num := 0; ; num++ {
+		if num%4096 == 0 && ctx.Err() != nil {
+			return nil, ctx.Err()
+		}
+		line, err := readLine(r)
+		if errors.Is(err, io.EOF) {
+			return matches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if match(line) {
+			matches = append(matches, num)
+		}
+	}
+}
+
+// writeLines writes the lines with the numbers to w. All lines are written if
+// nums is nil.
+func (lf *logFile) writeLines(w io.Writer, nums []int) error {
+	if nums == nil {
+		_, err := io.Copy(w, lf.section(0))
+		return err
+	}
+	for len(nums) > 0 {
+		n := min(len(nums), 4096)
+		lines, err := lf.readLines(nums[:n])
+		if err != nil {
+			return err
+		}
+		for _, line := range lines {
+			if _, err := io.WriteString(w, line+"\n"); err != nil {
+				return err
+			}
+		}
+		nums = nums[n:]
+	}
+	return nil
+}
+
+func (lf *logFile) Close() error {
+	return lf.f.Close()
+}
+
+// readLine reads a line without line ending. The last line of a file may
+// end without line ending.
+func readLine(r *bufio.Reader) (string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil && !(errors.Is(err, io.EOF) && line != "") {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\n")
+	return strings.TrimSuffix(line, "\r"), nil
+}
+
+// skipLine skips a line without keeping it.
+func skipLine(r *bufio.Reader) error {
+	for {
+		_, err := r.ReadSlice('\n')
+		if !errors.Is(err, bufio.ErrBufferFull) {
+			if errors.Is(err, io.EOF) {
+				return nil
+			}
+			return err
+		}
+	}
+}
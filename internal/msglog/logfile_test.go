@@ -0,0 +1,140 @@
+package msglog
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mahlburgc/teaterm/events"
+)
+
+// writeLogFile writes a file of n numbered lines, the last one without line
+// ending.
+func writeLogFile(t *testing.T, n int) string {
+	t.Helper()
+	var b strings.Builder
+	for i := range n {
+		fmt.Fprintf(&b, "line %d\r\n", i)
+	}
+	path := filepath.Join(t.TempDir(), "big.log")
+	if err := os.WriteFile(path, []byte(strings.TrimSuffix(b.String(), "\r\n")), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestLogFile(t *testing.T) {
+	n := 3*fileIndexStep + 10
+	lf, err := openLogFile(writeLogFile(t, n))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+
+	<-lf.indexed
+	if got := lf.lineCount(); got != n {
+		t.Errorf("lines = %d, want %d", got, n)
+	}
+
+	nums := []int{0, 1, fileIndexStep - 1, fileIndexStep, 2*fileIndexStep + 5, n - 1}
+	lines, err := lf.readLines(nums)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, num := range nums {
+		if want := fmt.Sprintf("line %d", num); lines[i] != want {
+			t.Errorf("line %d = %q, want %q", num, lines[i], want)
+		}
+	}
+
+	matches, err := lf.filter(context.Background(), func(line string) bool {
+		return strings.HasSuffix(line, "00")
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{100, 200, 300, 400, 500, 600, 700}; !slices.Equal(matches, want) {
+		t.Errorf("matches = %v, want %v", matches, want)
+	}
+
+	var b strings.Builder
+	if err := lf.writeLines(&b, matches[:2]); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := b.String(), "line 100\nline 200\n"; got != want {
+		t.Errorf("written lines = %q, want %q", got, want)
+	}
+}
+
+// The lines are shown while the file is indexed, the view is updated until
+// the index is complete.
+func TestLogFileIndexing(t *testing.T) {
+	n := 3*fileIndexStep + 10
+	m := New(false, false, false, lipgloss.NewStyle(), lipgloss.NewStyle(), lipgloss.NewStyle(),
+		nil, LogText, 100)
+	if err := m.ShowFile(writeLogFile(t, n)); err != nil {
+		t.Fatal(err)
+	}
+	defer m.CloseFile()
+
+	for cmd := m.Init(); cmd != nil; {
+		m, cmd = m.Update(cmd())
+	}
+	if got := m.lineCount(); got != n {
+		t.Errorf("lines = %d after indexing, want %d", got, n)
+	}
+	if strings.Contains(m.View(), "indexing") {
+		t.Error("still indexing after the index is complete")
+	}
+}
+
+func TestLogFileFilterCanceled(t *testing.T) {
+	lf, err := openLogFile(writeLogFile(t, 10))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer lf.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := lf.filter(ctx, func(string) bool { return true }); err == nil {
+		t.Error("canceled filter succeeded")
+	}
+}
+
+func TestLogFileFilterResults(t *testing.T) {
+	m := New(false, false, false, lipgloss.NewStyle(), lipgloss.NewStyle(), lipgloss.NewStyle(),
+		nil, LogText, 100)
+	if err := m.ShowFile(writeLogFile(t, 20)); err != nil {
+		t.Fatal(err)
+	}
+	defer m.CloseFile()
+
+	m, cmd := m.Update(events.MsgLogFilterStringMsg("line 1"))
+	if cmd == nil {
+		t.Fatal("no search started")
+	}
+	m, cmd = m.Update(events.MsgLogFilterStringMsg("line 2"))
+	if _, again := m.Update(events.MsgLogFilterStringMsg("line 2")); again != nil {
+		t.Error("search restarted for the same filter")
+	}
+
+	// the result of the old search may arrive, if it was done before the
+	// cancel
+	m, _ = m.Update(fileFilterMsg{id: 1, matches: []int{1}})
+	if m.fileMatches != nil {
+		t.Errorf("matches = %v of the old search", m.fileMatches)
+	}
+
+	msg := cmd()
+	m, _ = m.Update(msg)
+	m, _ = m.Update(msg)
+	if want := []int{2, 12}; !slices.Equal(m.fileMatches, want) {
+		t.Errorf("matches = %v, want %v", m.fileMatches, want)
+	}
+}
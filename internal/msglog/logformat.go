@@ -0,0 +1,75 @@
+package msglog
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"time"
+)
+
+// LogFormat is the format of the serial log file.
+type LogFormat string
+
+const (
+	LogText  LogFormat = "text"  // lines as shown, with the colors sent by the device
+	LogPlain LogFormat = "plain" // lines as shown, without colors
+	LogHex   LogFormat = "hex"   // rx and tx messages as hex dump
+	LogJSONL LogFormat = "jsonl" // one JSON object per message for other tools
+)
+
+// LogFormats lists the valid log formats.
+var LogFormats = []LogFormat{LogText, LogPlain, LogHex, LogJSONL}
+
+// LogRecord is a message of a JSONL log file. Raw holds the bytes of the
+// message, received messages without the framing delimiter and sent messages
+// with the line ending. Text is the message decoded as UTF-8.
+type LogRecord struct {
+	Time   time.Time `json:"time"`
+	Dir    string    `json:"dir"` // rx, tx, info or err
+	Port   string    `json:"port,omitempty"`
+	Source string    `json:"source,omitempty"` // tx data of another program
+	Raw    string    `json:"raw"`              // base64
+	Text   string    `json:"text"`
+}
+
+var logDirs = map[int]string{
+	rxMsg:   "rx",
+	txMsg:   "tx",
+	errMsg:  "err",
+	infoMsg: "info",
+}
+
+// formatJSON formats an entry as a line of a JSONL log file.
+func (m *Model) formatJSON(e entry) string {
+	record := LogRecord{
+		Time:   e.time,
+		Dir:    logDirs[e.msgType],
+		Port:   m.port,
+		Source: e.source,
+		Raw:    base64.StdEncoding.EncodeToString([]byte(e.data + e.lineEnding)),
+		Text:   e.data,
+	}
+	line, _ := json.Marshal(record)
+	return string(line)
+}
+
+// Data returns the bytes of the message.
+func (r LogRecord) Data() ([]byte, error) {
+	return base64.StdEncoding.DecodeString(r.Raw)
+}
+
+// LogHeader formats a header line of a serial log file, it is logged as an
+// info message with the text.
+func LogHeader(format LogFormat, port string, t time.Time, text string) string {
+	if format != LogJSONL {
+		return "INFO: " + text
+	}
+	record := LogRecord{
+		Time: t,
+		Dir:  logDirs[infoMsg],
+		Port: port,
+		Raw:  base64.StdEncoding.EncodeToString([]byte(text)),
+		Text: text,
+	}
+	line, _ := json.Marshal(record)
+	return string(line)
+}
@@ -0,0 +1,72 @@
+package msglog
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mahlburgc/teaterm/events"
+)
+
+// writeLog logs a sent and a colored received message in the given format.
+func writeLog(format LogFormat) string {
+	var buf bytes.Buffer
+	m := New(false, false, false, lipgloss.NewStyle(), lipgloss.NewStyle(), lipgloss.NewStyle(),
+		log.New(&buf, "", 0), format, 100)
+	m.SetPort("/dev/ttyUSB0")
+	m.Update(events.SerialTxMsg{Data: "status", LineEnding: "\r\n"})
+	m.Update(events.SerialRxMsgReceived("\x1b[32mok\x1b[0m"))
+	return buf.String()
+}
+
+func TestPlainLog(t *testing.T) {
+	if got := writeLog(LogText); !strings.Contains(got, "\x1b[32mok") {
+		t.Errorf("text log %q lost the colors", got)
+	}
+	if got, want := writeLog(LogPlain), "status<CR><LF>\nok\n"; got != want {
+		t.Errorf("plain log = %q, want %q", got, want)
+	}
+}
+
+func TestJSONLLog(t *testing.T) {
+	lines := strings.Split(strings.TrimSpace(writeLog(LogJSONL)), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2", len(lines))
+	}
+
+	want := []LogRecord{
+		{Dir: "tx", Port: "/dev/ttyUSB0", Raw: "status\r\n", Text: "status"},
+		{Dir: "rx", Port: "/dev/ttyUSB0", Raw: "\x1b[32mok\x1b[0m", Text: "\x1b[32mok\x1b[0m"},
+	}
+	for i, line := range lines {
+		var r LogRecord
+		if err := json.Unmarshal([]byte(line), &r); err != nil {
+			t.Fatalf("line %d: %v", i, err)
+		}
+		raw, _ := base64.StdEncoding.DecodeString(r.Raw)
+		if r.Dir != want[i].Dir || r.Port != want[i].Port || string(raw) != want[i].Raw || r.Text != want[i].Text {
+			t.Errorf("line %d = %+v, want %+v", i, r, want[i])
+		}
+		if r.Time.IsZero() {
+			t.Errorf("line %d has no time", i)
+		}
+	}
+}
+
+func TestTextLog(t *testing.T) {
+	var buf bytes.Buffer
+	m := New(false, false, false, lipgloss.NewStyle(), lipgloss.NewStyle(), lipgloss.NewStyle(),
+		log.New(io.Discard, "", 0), LogJSONL, 100)
+	m.SetTextLog(log.New(&buf, "", 0))
+	m.Update(events.SerialTxMsg{Data: "status", LineEnding: "\r\n"})
+	m.Update(events.SerialRxMsgReceived("ok"))
+
+	if got, want := buf.String(), "status<CR><LF>\nok\n"; got != want {
+		t.Errorf("text log = %q, want %q", got, want)
+	}
+}
@@ -1,11 +1,16 @@
 package msglog
 
 import (
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"os/exec"
+	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
 	"strings"
 	"time"
@@ -28,17 +33,45 @@ type Model struct {
 	log           []string
 	logFiltered   []string
 	showTimestamp bool
-	serialLog     *log.Logger
-	txPrefix      string
-	rxPrefix      string
-	errPrefix     string
-	infoPrefix    string
-	showEscapes   bool
-	logLimit      int
-	msgCnt        int // rx and tx messages during one session
-	filterString  string
-	scrollIndex   int
-	needsUpdate   bool
+	// timestamps are shown in the view even if showTimestamp is not set
+	forceTimestamps bool
+	title           string
+	serialLog       *log.Logger
+	textLog         *log.Logger // gets the messages as text lines, whatever the log format
+	txPrefix        string
+	rxPrefix        string
+	errPrefix       string
+	infoPrefix      string
+	showEscapes     bool
+	logLimit        int
+	msgCnt          int // rx and tx messages during one session
+	filterString    string
+	scrollIndex     int
+	needsUpdate     bool
+	entries         []entry          // messages shown in the log, log holds their rendered lines
+	hexView         bool             // show rx and tx messages as hex dump
+	logFormat       LogFormat        // format of the serial log
+	port            string           // port of the messages, for the serial log
+	partialIdx      int              // entry index of a shown unterminated rx message, -1 if none
+	splitData       []byte           // parts of a split rx line, not yet written to the serial log
+	splitTime       time.Time        // receive time of the first part of a split rx line
+	now             func() time.Time // time of new messages
+	// file shown instead of the messages, see logfile.go
+	file         *logFile
+	fileMatches  []int              // numbers of the lines matching the filter, nil without filter
+	filterCancel context.CancelFunc // stops the running search of the file, nil if none
+	filterID     int                // id of the last search of the file
+}
+
+// entry is a single logged message. It is kept unrendered, so the log can be
+// rendered again if the view changes.
+type entry struct {
+	time       time.Time
+	msgType    int
+	data       string
+	lineEnding string
+	source     string // tx data of another program, e.g. "pty"
+	lines      int    // number of rendered lines in the log
 }
 
 // This message is sent when the editor is closed.
@@ -46,6 +79,15 @@ type EditorFinishedMsg struct {
 	err error
 }
 
+// Sent when more lines of the shown file are indexed.
+type fileIndexedMsg struct{}
+
+// Sent when the search of the shown file for the filter is done.
+type fileFilterMsg struct {
+	id      int
+	matches []int
+}
+
 const (
 	rxMsg = iota
 	txMsg
@@ -54,8 +96,9 @@ const (
 )
 
 // New creates a new model with default settings.
-func New(showTimestamp bool, showEscapes bool, sendStyle lipgloss.Style,
-	errStyle lipgloss.Style, infoStyle lipgloss.Style, serialLog *log.Logger, logLimit int,
+// The messages are written to the serial log in the given format.
+func New(showTimestamp bool, showEscapes bool, hexView bool, sendStyle lipgloss.Style,
+	errStyle lipgloss.Style, infoStyle lipgloss.Style, serialLog *log.Logger, logFormat LogFormat, logLimit int,
 ) (m Model) {
 	// Serial viewport contains all sent and received messages.
 	// We will create a viewport without border and later manually
@@ -79,10 +122,13 @@ func New(showTimestamp bool, showEscapes bool, sendStyle lipgloss.Style,
 	m.infoPrefix = "INFO: "
 	m.serialLog = serialLog
 	m.showEscapes = showEscapes
+	m.hexView = hexView
+	m.logFormat = logFormat
 	m.logLimit = logLimit
 	m.msgCnt = 0
 	m.filterString = ""
 	m.needsUpdate = false
+	m.partialIdx = -1
 
 	m.log = append(m.log, m.startMsg())
 	m.logFiltered = append(m.logFiltered, m.startMsg())
@@ -91,34 +137,191 @@ func New(showTimestamp bool, showEscapes bool, sendStyle lipgloss.Style,
 	m.errStyle = errStyle
 	m.infoStyle = infoStyle
 	m.showTimestamp = showTimestamp
+	m.title = "Messages"
+	m.now = time.Now
 
 	return m
 }
 
+// SetPort sets the port written to the serial log with every message.
+func (m *Model) SetPort(port string) {
+	m.port = port
+}
+
+// SetSerialLog sets the logger of the serial log, nil stops logging.
+func (m *Model) SetSerialLog(serialLog *log.Logger) {
+	m.serialLog = serialLog
+}
+
+// SetTextLog sets the logger getting the messages as text lines like the text
+// log format, e.g. for share clients. Nil stops it.
+func (m *Model) SetTextLog(textLog *log.Logger) {
+	m.textLog = textLog
+}
+
+// LogScrollback writes the messages of the log to the serial log, e.g. when
+// logging starts during a session. A shown unterminated rx message is logged
+// once it is complete.
+func (m *Model) LogScrollback() {
+	for i, e := range m.entries {
+		if i != m.partialIdx {
+			m.writeSerialLog(e)
+		}
+	}
+}
+
+// ShowFile shows the lines of the file instead of the messages, read-only.
+// The lines are read from the file when they are shown. The file is indexed
+// in the background, see Init.
+func (m *Model) ShowFile(path string) error {
+	file, err := openLogFile(path)
+	if err != nil {
+		return err
+	}
+	m.file = file
+	m.title = filepath.Base(path)
+	m.needsUpdate = true
+	return nil
+}
+
+// CloseFile closes the shown file.
+func (m *Model) CloseFile() {
+	if m.filterCancel != nil {
+		m.filterCancel()
+	}
+	if m.file != nil {
+		m.file.Close()
+	}
+}
+
+// SetClock sets the clock giving the time of new messages, e.g. the recorded
+// time of replayed messages.
+func (m *Model) SetClock(now func() time.Time) {
+	m.now = now
+}
+
+// SetTitle sets the title shown in the border of the log.
+func (m *Model) SetTitle(title string) {
+	m.title = title
+}
+
+// ForceTimestamps shows timestamps in the view, even if they are disabled,
+// e.g. to compare the logs of two ports. The serial log is not changed.
+func (m *Model) ForceTimestamps(force bool) {
+	if m.forceTimestamps == force {
+		return
+	}
+	m.forceTimestamps = force
+	if !m.showTimestamp {
+		m.rebuildLog()
+		m.UpdateVp()
+	}
+}
+
+// Init shows the lines of a shown file as they are indexed.
+func (m Model) Init() tea.Cmd {
+	return m.waitIndex()
+}
+
+// waitIndex returns a command waiting until more lines of the shown file are
+// indexed, nil if the index is complete.
+func (m Model) waitIndex() tea.Cmd {
+	if m.file == nil {
+		return nil
+	}
+	if done, _ := m.file.done(); done {
+		return nil
+	}
+	file := m.file
+	return func() tea.Msg {
+		if !file.waitProgress() {
+			return nil
+		}
+		return fileIndexedMsg{}
+	}
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	// viewport will be managed completely manually,
 	// so viewports update function will not be called.
+	var cmd tea.Cmd
 
 	switch msg := msg.(type) {
 
 	case events.MsgLogFilterStringMsg:
+		if m.file != nil && string(msg) == m.filterString {
+			return m, nil // the search of the file is running or done
+		}
 		m.filterString = string(msg)
-		m.scrollIndex = 0           // reset scrolling
-		m.filterLog(m.filterString) // only filter whole log if new filter string was set
+		m.scrollIndex = 0 // reset scrolling
+		if m.file != nil {
+			cmd = m.filterFile()
+		} else {
+			m.filterLog(m.filterString) // only filter whole log if new filter string was set
+		}
+
+	case fileIndexedMsg:
+		if m.file == nil {
+			return m, nil
+		}
+		m.needsUpdate = true
+		if done, err := m.file.done(); done && err != nil {
+			cmd = func() tea.Msg {
+				return events.ErrMsg(err)
+			}
+		} else {
+			cmd = m.waitIndex()
+		}
 
-	case events.SendMsg:
-		m.addMsg(msg.Data, txMsg)
+	case fileFilterMsg:
+		if m.file == nil || msg.id != m.filterID {
+			return m, nil // result of an old search
+		}
+		if m.filterCancel != nil {
+			m.filterCancel()
+			m.filterCancel = nil
+		}
+		m.fileMatches = msg.matches
+		m.needsUpdate = true
+
+	case events.SerialTxMsg:
+		m.addMsg(msg.Data, msg.LineEnding, txMsg)
+
+	case events.ForwardedTxMsg:
+		m.addForwardedMsg(msg.Data, msg.LineEnding, msg.Source)
 
 	case events.SerialRxMsgReceived:
-		m.addMsg(string(msg), rxMsg)
+		if m.partialIdx >= 0 {
+			m.completePartialMsg(string(msg))
+		} else {
+			m.addRxMsg(string(msg))
+		}
+
+	case events.SerialRxSplitMsg:
+		m.addSplitMsg(string(msg))
+
+	case events.SerialRxPartialMsg:
+		m.addPartialMsg(string(msg))
+
+	case events.ConnectionStatusMsg:
+		// the rest of an unterminated message will not arrive anymore
+		if m.partialIdx >= 0 {
+			e := m.entries[m.partialIdx]
+			if !m.flushSplitData(e.data) {
+				m.writeLog(e)
+			}
+			m.partialIdx = -1
+		} else {
+			m.flushSplitData("")
+		}
 
 	case events.ErrMsg:
 		if msg != nil {
-			m.addMsg(msg.Error(), errMsg)
+			m.addMsg(msg.Error(), "", errMsg)
 		}
 
 	case events.InfoMsg:
-		m.addMsg(string(msg), infoMsg)
+		m.addMsg(string(msg), "", infoMsg)
 
 	case tea.MouseMsg:
 		switch msg.Button {
@@ -156,15 +359,22 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 			m.scrollToBottom()
 
 		case key.Matches(msg, keymap.Default.OpenEditorKey):
+			if m.file != nil {
+				return m, m.editFileCmd()
+			}
 			return m, openEditorCmd(m.logFiltered)
 
+		case key.Matches(msg, keymap.Default.HexViewKey):
+			if m.file != nil {
+				break // file lines are shown as they are
+			}
+			m.hexView = !m.hexView
+			m.rebuildLog()
+			m.scrollToBottom()
+
 		case key.Matches(msg, keymap.Default.ClearLogKey):
-			if m.Vp.Height > 0 {
-				m.log = nil /* reset serial message log */
-				m.logFiltered = nil
-				m.msgCnt = 0
-				m.Vp.SetContent("")
-				m.scrollToBottom()
+			if m.Vp.Height > 0 && m.file == nil {
+				m.Clear()
 			}
 		}
 
@@ -177,7 +387,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		m.UpdateVp()
 	}
 
-	return m, nil
+	return m, cmd
 }
 
 func (m Model) View() string {
@@ -195,8 +405,24 @@ func (m Model) View() string {
 
 	scrollPercentageString := percentRenderStyle.Render(fmt.Sprintf("%3d%%", int(scrollPercentage)))
 
-	footer := borderStyle.Render(fmt.Sprintf("%d ", m.msgCnt)) + scrollPercentageString
-	return styles.AddBorder(m.Vp, "Messages", footer, true)
+	count := m.msgCnt
+	if m.file != nil {
+		count = m.lineCount()
+	}
+	footer := borderStyle.Render(fmt.Sprintf("%d ", count)) + scrollPercentageString
+	title := m.title
+	if m.hexView {
+		title += " (hex)"
+	}
+	if m.file != nil {
+		if done, _ := m.file.done(); !done {
+			title += " (indexing...)"
+		}
+	}
+	if m.filterCancel != nil {
+		title += " (filtering...)"
+	}
+	return styles.AddBorder(m.Vp, title, footer, true)
 }
 
 func (m *Model) SetSize(width, height int) {
@@ -210,6 +436,21 @@ func (m *Model) SetSize(width, height int) {
 	m.UpdateVp()
 }
 
+// Clear removes all messages from the log.
+// The rest of a split line is logged as a line of its own.
+func (m *Model) Clear() {
+	// like a new log, trimLog keeps the start message in front of the entries
+	m.log = []string{m.startMsg()}
+	m.logFiltered = []string{m.startMsg()}
+	m.entries = nil
+	m.msgCnt = 0
+	m.partialIdx = -1
+	m.splitData = nil
+	m.splitTime = time.Time{}
+	m.Vp.SetContent("")
+	m.scrollToBottom()
+}
+
 func (m *Model) scrollUp(n int) {
 	if m.atTop() {
 		return
@@ -225,7 +466,7 @@ func (m *Model) scrollUp(n int) {
 }
 
 func (m *Model) maxScrollIndex() int {
-	return len(m.logFiltered) - m.Vp.Height
+	return m.lineCount() - m.Vp.Height
 }
 
 func (m *Model) scrollToTop() {
@@ -263,7 +504,7 @@ func (m *Model) scrollDown(n int) {
 }
 
 func (m *Model) atTop() bool {
-	if len(m.logFiltered) > m.Vp.Height {
+	if m.lineCount() > m.Vp.Height {
 		return m.scrollIndex == m.maxScrollIndex()
 	} else {
 		return true
@@ -271,82 +512,320 @@ func (m *Model) atTop() bool {
 }
 
 func (m *Model) atBottom() bool {
-	if len(m.logFiltered) > m.Vp.Height {
+	if m.lineCount() > m.Vp.Height {
 		return m.scrollIndex == 0
 	} else {
 		return true
 	}
 }
 
-// Log a message to the viewport
-func (m *Model) addMsg(msg string, msgType int) {
-	var line strings.Builder
-	if m.showTimestamp {
-		t := time.Now().Format("15:04:05.000")
-		line.WriteString(fmt.Sprintf("[%s] ", t))
+// Log a message to the viewport.
+// The line ending is only shown if escapes are shown, but always
+// written to the serial log to record the exact bytes sent.
+func (m *Model) addMsg(msg string, lineEnding string, msgType int) {
+	if msgType == rxMsg || msgType == txMsg {
+		m.msgCnt++
+	}
+
+	e := entry{time: m.now(), msgType: msgType, data: msg, lineEnding: lineEnding}
+	m.writeLog(e)
+	m.appendEntry(e)
+}
+
+// Log tx data of another program tagged with its source. Without line
+// ending, a trailing line ending of the data is handled like the line ending
+// of typed messages.
+func (m *Model) addForwardedMsg(data string, lineEnding string, source string) {
+	m.msgCnt++
+
+	if lineEnding == "" {
+		msg := strings.TrimRight(data, "\r\n")
+		data, lineEnding = msg, data[len(msg):]
+	}
+	e := entry{time: m.now(), msgType: txMsg, data: data, lineEnding: lineEnding, source: source}
+	m.writeLog(e)
+	m.appendEntry(e)
+}
+
+// Log a complete rx message to the viewport.
+// If it ends a split line, the whole line is written to the serial log.
+func (m *Model) addRxMsg(msg string) {
+	m.msgCnt++
+	e := entry{time: m.now(), msgType: rxMsg, data: msg}
+	if !m.flushSplitData(msg) {
+		m.writeLog(e)
 	}
+	m.appendEntry(e)
+}
+
+// Log a part of a split rx line to the viewport.
+// The serial log gets the whole line once the last part is received.
+func (m *Model) addSplitMsg(msg string) {
+	t := m.now()
+	if m.partialIdx >= 0 {
+		t = m.entries[m.partialIdx].time
+		m.replacePartialMsg(msg)
+		m.partialIdx = -1
+	} else {
+		m.msgCnt++
+		m.appendEntry(entry{time: t, msgType: rxMsg, data: msg})
+	}
+
+	if len(m.splitData) == 0 {
+		m.splitTime = t
+	}
+	m.splitData = append(m.splitData, msg...)
+}
+
+// Write a pending split line, ended by msg, to the serial log.
+// Returns false if there is no pending split line.
+func (m *Model) flushSplitData(msg string) bool {
+	if len(m.splitData) == 0 {
+		return false
+	}
+	m.writeLog(entry{time: m.splitTime, msgType: rxMsg, data: string(m.splitData) + msg})
+	m.splitData = nil
+	return true
+}
+
+// Log an unterminated rx message to the viewport.
+// The message is updated in place until it is complete, so it is only written
+// to the serial log once it is complete.
+func (m *Model) addPartialMsg(msg string) {
+	if m.partialIdx < 0 {
+		m.msgCnt++
+		m.appendEntry(entry{time: m.now(), msgType: rxMsg, data: msg})
+		m.partialIdx = len(m.entries) - 1
+		return
+	}
+
+	m.replacePartialMsg(msg)
+}
+
+// Replace the unterminated rx message with the complete message.
+func (m *Model) completePartialMsg(msg string) {
+	e := m.entries[m.partialIdx]
+	e.data = msg
+	if !m.flushSplitData(msg) {
+		m.writeLog(e)
+	}
+	m.replacePartialMsg(msg)
+	m.partialIdx = -1
+}
+
+func (m *Model) replacePartialMsg(msg string) {
+	e := m.entries[m.partialIdx]
+	e.data = msg
+
+	if m.partialIdx < len(m.entries)-1 {
+		// e.g. the typed username was sent in between, update the entry in place
+		m.entries[m.partialIdx] = e
+		m.rebuildLog()
+		return
+	}
+
+	// Common case, nothing was logged after the partial message.
+	// Remove its lines and append it again, so the filter is applied as usual.
+	for range e.lines {
+		last := m.log[len(m.log)-1]
+		m.log = m.log[:len(m.log)-1]
+		if n := len(m.logFiltered); n > 0 && m.logFiltered[n-1] == last {
+			m.logFiltered = m.logFiltered[:n-1]
+			if m.scrollIndex > 0 {
+				m.scrollIndex-- // appendEntry scrolls up again
+			}
+		}
+	}
+	m.entries = m.entries[:m.partialIdx]
+	m.appendEntry(e)
+}
+
+// Format a message as a single log line without styles.
+func (m *Model) formatMsg(e entry, timestamp bool) string {
+	var line strings.Builder
+	writeTimestamp(&line, e.time, timestamp)
 
-	switch msgType {
+	switch e.msgType {
 	case txMsg:
 		line.WriteString(m.txPrefix)
-		m.msgCnt++
+		if e.source != "" {
+			line.WriteString("[" + e.source + "] ")
+		}
 	case errMsg:
 		line.WriteString(m.errPrefix)
 	case infoMsg:
 		line.WriteString(m.infoPrefix)
 	default:
 		line.WriteString(m.rxPrefix)
-		m.msgCnt++
 	}
 
 	if m.showEscapes {
 		// line.WriteString(fmt.Sprintf("%q", msg)) can be used as alternative
-		line.WriteString(yatStyleFormatter(msg))
+		line.WriteString(yatStyleFormatter(e.data + e.lineEnding))
 	} else {
-		line.WriteString(sanitizeAndKeepColors(msg))
+		line.WriteString(sanitizeAndKeepColors(e.data))
 	}
 
-	if m.serialLog != nil {
-		m.serialLog.Println(line.String())
+	return line.String()
+}
+
+// Format a rx or tx message as hex dump without styles.
+// The timestamp is only written in front of the first row.
+func (m *Model) formatHexMsg(e entry, timestamp bool) []string {
+	var ts strings.Builder
+	writeTimestamp(&ts, e.time, timestamp)
+
+	tag := "RX "
+	if e.msgType == txMsg {
+		tag = "TX "
+	}
+	if e.source != "" {
+		tag += "[" + e.source + "] "
 	}
 
+	rows := hexDump(e.data + e.lineEnding)
+	for i := range rows {
+		prefix := ts.String()
+		if i > 0 {
+			prefix = strings.Repeat(" ", ts.Len())
+		}
+		rows[i] = prefix + tag + rows[i]
+	}
+	return rows
+}
+
+func writeTimestamp(sb *strings.Builder, t time.Time, timestamp bool) {
+	if timestamp {
+		sb.WriteString(fmt.Sprintf("[%s] ", t.Format("15:04:05.000")))
+	}
+}
+
+// writeLog writes a new message to the serial log and the text log.
+func (m *Model) writeLog(e entry) {
+	if m.textLog != nil {
+		m.textLog.Println(m.formatLogLine(e, LogText))
+	}
+	m.writeSerialLog(e)
+}
+
+func (m *Model) writeSerialLog(e entry) {
+	if m.serialLog == nil {
+		return
+	}
+
+	switch {
+	case m.logFormat == LogJSONL:
+		m.serialLog.Println(m.formatJSON(e))
+		return
+
+	case m.logFormat == LogHex && (e.msgType == rxMsg || e.msgType == txMsg):
+		for _, row := range m.formatHexMsg(e, m.showTimestamp) {
+			m.serialLog.Println(row)
+		}
+		return
+	}
+
+	m.serialLog.Println(m.formatLogLine(e, m.logFormat))
+}
+
+// formatLogLine formats an entry as line of a text or plain log.
+func (m *Model) formatLogLine(e entry, format LogFormat) string {
+	line := m.formatMsg(e, m.showTimestamp)
+	if format == LogPlain {
+		line = colorSeqRegex.ReplaceAllString(line, "")
+	}
+	if m.showEscapes {
+		return line
+	}
+	return line + yatStyleFormatter(e.lineEnding)
+}
+
+// Render the log lines of an entry for the current view.
+func (m *Model) renderEntry(e entry) []string {
+	var lines []string
+	timestamp := m.showTimestamp || m.forceTimestamps
+	if m.hexView && (e.msgType == rxMsg || e.msgType == txMsg) {
+		lines = m.formatHexMsg(e, timestamp)
+	} else {
+		lines = []string{m.formatMsg(e, timestamp)}
+	}
+
+	for i, line := range lines {
+		switch e.msgType {
+		case txMsg:
+			lines[i] = m.sendStyle.Render(line)
+		case errMsg:
+			lines[i] = m.errStyle.Render(line)
+		case infoMsg:
+			lines[i] = m.infoStyle.Render(line)
+		}
+	}
+	return lines
+}
+
+// Append an entry to the log and its lines to the filtered log.
+// A shown file is not changed.
+func (m *Model) appendEntry(e entry) {
+	if m.file != nil {
+		return
+	}
 	atBottom := m.atBottom()
 
-	var renderedString string
-	switch msgType {
-	case txMsg:
-		renderedString = m.sendStyle.Render(line.String())
-	case errMsg:
-		renderedString = m.errStyle.Render(line.String())
-	case infoMsg:
-		renderedString = m.infoStyle.Render(line.String())
-	case rxMsg:
-		renderedString = line.String()
-	default:
-		renderedString = line.String()
+	lines := m.renderEntry(e)
+	e.lines = len(lines)
+	m.entries = append(m.entries, e)
+
+	matches := 0
+	for _, line := range lines {
+		m.log = append(m.log, line)
+		if m.appendToFilteredLog(line, m.filterString) {
+			matches++
+		}
+	}
+
+	m.trimLog()
+
+	// always reset vp to bottom if we send new messages or receive info or error messages
+	if e.msgType != rxMsg {
+		m.scrollToBottom()
+	} else if atBottom == false && matches > 0 {
+		m.scrollUp(matches)
 	}
+}
 
-	m.log = append(m.log, renderedString)
-	matchFound := m.appendToFilteredLog(renderedString, m.filterString)
+// Render all entries again, e.g. after the view changed.
+func (m *Model) rebuildLog() {
+	m.log = []string{m.startMsg()}
+	for i := range m.entries {
+		lines := m.renderEntry(m.entries[i])
+		m.entries[i].lines = len(lines)
+		m.log = append(m.log, lines...)
+	}
+	m.trimLog()
+	m.filterLog(m.filterString)
+
+	if m.scrollIndex > max(m.maxScrollIndex(), 0) {
+		m.scrollIndex = max(m.maxScrollIndex(), 0)
+	}
+}
 
+// Remove the oldest entries if the log exceeds its limit.
+func (m *Model) trimLog() {
 	// message histrory limit, remove oldest if exceed
-	if len(m.log) > m.logLimit {
-		m.log = m.log[1:]
+	for len(m.log) > m.logLimit && len(m.entries) > 0 {
+		m.log = m.log[m.entries[0].lines:]
 		m.log[0] = m.startMsg()
+		m.entries = m.entries[1:]
+		if m.partialIdx >= 0 {
+			m.partialIdx-- // -1 if the partial message was removed
+		}
 	}
 
 	// filtered message histrory limit, remove oldest if exceed
-	if len(m.logFiltered) > m.logLimit {
-		m.logFiltered = m.logFiltered[1:]
+	if over := len(m.logFiltered) - m.logLimit; over > 0 {
+		m.logFiltered = m.logFiltered[over:]
 		m.logFiltered[0] = m.startMsg()
 	}
-
-	// always reset vp to bottom if we send new messages or receive info or error messages
-	if msgType != rxMsg {
-		m.scrollToBottom()
-	} else if atBottom == false && matchFound {
-		m.scrollUp(1)
-	}
 }
 
 // yatStyleFormatter converts raw serial data into a safely readable string.
@@ -456,7 +935,7 @@ func (m *Model) UpdateVp() {
 
 	startIndex := m.getFirstViewableElementIndex()
 	stopIndex := m.getLastViewableElementIndex()
-	content := strings.Join(m.logFiltered[startIndex:stopIndex], "\n")
+	content := strings.Join(m.viewLines(startIndex, stopIndex), "\n")
 
 	// Highlighting logic -> highlight filter matches if currently filtering
 	if m.filterString != "" {
@@ -490,12 +969,48 @@ func (m *Model) UpdateVp() {
 	m.Vp.SetContent(content)
 }
 
+// lineCount returns the number of lines of the filtered log.
+func (m *Model) lineCount() int {
+	switch {
+	case m.file == nil:
+		return len(m.logFiltered)
+	case m.fileMatches != nil:
+		return len(m.fileMatches)
+	}
+	return m.file.lineCount()
+}
+
+// viewLines returns the lines of the filtered log from start to stop. The
+// lines of a shown file are read and rendered like received messages.
+func (m *Model) viewLines(start, stop int) []string {
+	if m.file == nil {
+		return m.logFiltered[start:stop]
+	}
+
+	var nums []int
+	if m.fileMatches != nil {
+		nums = m.fileMatches[start:stop]
+	} else {
+		for num := start; num < stop; num++ {
+			nums = append(nums, num)
+		}
+	}
+	lines, err := m.file.readLines(nums)
+	for i, line := range lines {
+		lines[i] = m.formatMsg(entry{msgType: rxMsg, data: line}, false)
+	}
+	if err != nil {
+		lines = append(lines, m.errStyle.Render(m.errPrefix+err.Error()))
+	}
+	return lines
+}
+
 func (m Model) GetLen() int {
 	return len(m.log)
 }
 
 func (m *Model) contentFitsInVp() bool {
-	return len(m.logFiltered) <= m.Vp.Height
+	return m.lineCount() <= m.Vp.Height
 }
 
 func (m *Model) getFirstViewableElementIndex() int {
@@ -507,9 +1022,9 @@ func (m *Model) getFirstViewableElementIndex() int {
 
 func (m *Model) getLastViewableElementIndex() int {
 	if m.contentFitsInVp() {
-		return len(m.logFiltered)
+		return m.lineCount()
 	}
-	return len(m.logFiltered) - m.scrollIndex
+	return m.lineCount() - m.scrollIndex
 }
 
 func (m Model) GetScrollPercent() float64 {
@@ -522,12 +1037,32 @@ func (m Model) GetScrollPercent() float64 {
 
 // openEditorCmd creates a tea.Cmd that runs the editor.
 func openEditorCmd(content []string) tea.Cmd {
-	// Get the editor from the environment variable. Default to vim.
-	editor := os.Getenv("EDITOR")
-	if editor == "" {
-		editor = "vi"
+	return editTempFileCmd(func(w io.Writer) error {
+		// Write the viewport content to the temp file.
+		for _, line := range content {
+			if _, err := io.WriteString(w, stripansi.Strip(line)+"\n"); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// editFileCmd opens the shown file in the editor. While filtering, the
+// matching lines are passed in a temporary file instead.
+func (m *Model) editFileCmd() tea.Cmd {
+	if m.fileMatches == nil {
+		return editorCmd(m.file.path, false)
 	}
+	file, matches := m.file, m.fileMatches
+	return editTempFileCmd(func(w io.Writer) error {
+		return file.writeLines(w, matches)
+	})
+}
 
+// editTempFileCmd creates a temporary file with the content written by
+// write and opens it in the editor.
+func editTempFileCmd(write func(w io.Writer) error) tea.Cmd {
 	// Create a temporary file to store the content.
 	tmpFile, err := os.CreateTemp("", "bubbletea-edit-*.txt")
 	if err != nil {
@@ -536,12 +1071,10 @@ func openEditorCmd(content []string) tea.Cmd {
 		}
 	}
 
-	// Write the viewport content to the temp file.
-	for _, line := range content {
-		if _, err = tmpFile.WriteString(stripansi.Strip(line) + "\n"); err != nil {
-			return func() tea.Msg {
-				return EditorFinishedMsg{err: err}
-			}
+	if err := write(tmpFile); err != nil {
+		tmpFile.Close()
+		return func() tea.Msg {
+			return EditorFinishedMsg{err: err}
 		}
 	}
 
@@ -552,18 +1085,30 @@ func openEditorCmd(content []string) tea.Cmd {
 		}
 	}
 
+	return editorCmd(tmpFile.Name(), true)
+}
+
+// editorCmd runs the editor with the file, a temporary file is removed
+// afterwards.
+func editorCmd(path string, temporary bool) tea.Cmd {
+	// Get the editor from the environment variable. Default to vim.
+	editor := os.Getenv("EDITOR")
+	if editor == "" {
+		editor = "vi"
+	}
+
 	// This is the command that will be executed.
-	c := exec.Command(editor, tmpFile.Name())
+	c := exec.Command(editor, path)
 
 	// The magic is here: tea.ExecProcess handles suspending the Bubble Tea
 	// app, running the command, and then sending a message back.
 	return tea.ExecProcess(c, func(err error) tea.Msg {
-		if err != nil {
+		if err != nil || !temporary {
 			return EditorFinishedMsg{err: err}
 		}
 
 		// Clean up the temporary file.
-		err = os.Remove(tmpFile.Name())
+		err = os.Remove(path)
 
 		return EditorFinishedMsg{err: err}
 	})
@@ -596,7 +1141,8 @@ func (m *Model) appendToFilteredLog(line string, query string) bool {
 // logic fast.
 func (m *Model) filterLog(query string) {
 	if query == "" {
-		m.logFiltered = m.log
+		// copy, the log is trimmed by entries but the filtered log by lines
+		m.logFiltered = slices.Clone(m.log)
 	} else {
 		searchWords := strings.Fields(strings.ToLower(query))
 
@@ -616,16 +1162,59 @@ func (m *Model) filterLog(query string) {
 
 // filterString performs the actual matching.
 func (m *Model) filterMsg(line string, searchWords []string) (string, bool) {
+	if !containsWords(line, searchWords) {
+		return "", false
+	}
+	return line, true
+}
+
+// containsWords reports whether the line contains all lower case words,
+// ignoring case.
+func containsWords(line string, words []string) bool {
 	lowerLine := strings.ToLower(line)
 
 	// Fast Check: Basic string matching (cheap)
-	for _, word := range searchWords {
+	for _, word := range words {
 		if !strings.Contains(lowerLine, word) {
-			return "", false
+			return false
 		}
 	}
+	return true
+}
 
-	return line, true
+// filterFile starts to search the shown file for the lines matching the
+// filter. The search runs in the background, the lines matching the last
+// filter are shown until it is done.
+func (m *Model) filterFile() tea.Cmd {
+	if m.filterCancel != nil {
+		m.filterCancel()
+		m.filterCancel = nil
+	}
+	m.filterID++ // results of older searches are ignored
+
+	searchWords := strings.Fields(strings.ToLower(m.filterString))
+	if len(searchWords) == 0 {
+		m.fileMatches = nil
+		m.needsUpdate = true
+		return nil
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	m.filterCancel = cancel
+	id := m.filterID
+	file := m.file
+	return func() tea.Msg {
+		matches, err := file.filter(ctx, func(line string) bool {
+			return containsWords(line, searchWords)
+		})
+		switch {
+		case errors.Is(err, context.Canceled):
+			return nil
+		case err != nil:
+			return events.ErrMsg(err)
+		}
+		return fileFilterMsg{id: id, matches: matches}
+	}
 }
 
 // Creates a payload designed to absolutely
@@ -0,0 +1,39 @@
+package msglog
+
+import (
+	"bytes"
+	"fmt"
+	"log"
+	"strings"
+	"testing"
+
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mahlburgc/teaterm/events"
+)
+
+func TestClear(t *testing.T) {
+	var buf bytes.Buffer
+	m := New(false, false, false, lipgloss.NewStyle(), lipgloss.NewStyle(), lipgloss.NewStyle(),
+		log.New(&buf, "", 0), LogPlain, 4)
+
+	// the pending split line is dropped with the log
+	m, _ = m.Update(events.SerialRxSplitMsg("first part "))
+	m.Clear()
+	m, _ = m.Update(events.SerialRxMsgReceived("after clear"))
+	if got, want := buf.String(), "after clear\n"; got != want {
+		t.Errorf("serial log = %q, want %q", got, want)
+	}
+
+	for i := range 6 {
+		m, _ = m.Update(events.SerialRxMsgReceived(fmt.Sprintf("line %d", i)))
+	}
+	if len(m.log) > 4 || m.log[0] != m.startMsg() {
+		t.Fatalf("log after clear = %q, want the start message and at most 3 lines", m.log)
+	}
+	if last := m.log[len(m.log)-1]; !strings.Contains(last, "line 5") {
+		t.Errorf("last line = %q, want line 5", last)
+	}
+	if len(m.log) != len(m.entries)+1 {
+		t.Errorf("%d lines for %d entries", len(m.log), len(m.entries))
+	}
+}
@@ -0,0 +1,194 @@
+package internal
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/share"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+// time to wait for answers after the last line of stdin was sent
+const pipeDrainTime = 500 * time.Millisecond
+
+type (
+	// Sent when stdin is closed.
+	stdinClosedMsg struct{}
+	// Sent after the drain time.
+	pipeDrainedMsg struct{}
+)
+
+// pipeModel connects stdin and stdout to the port without TUI.
+// The session handles the port including reconnects, the message log writes
+// the serial log file.
+type pipeModel struct {
+	session session.Model
+	msglog  msglog.Model
+	share   share.Model
+	out     io.Writer // received data
+	errOut  io.Writer // infos and errors
+	// print a timestamp in front of every received line
+	showTimestamp bool
+	// bytes of the current frame already printed, it was received as
+	// partial message before
+	lineWritten int
+	// the current line is started, a split frame continues it
+	midLine bool
+	// sent lines not yet written to the port
+	pending int
+	eof     bool
+}
+
+func newPipeModel(port *io.ReadWriteCloser, settings session.Settings, flags Flags, serialLog *log.Logger,
+	out, errOut io.Writer,
+) pipeModel {
+	// only used for the serial log file and the share clients
+	serialMsglog := msglog.New(flags.Timestamp, flags.ShowEscapes, false, styles.VpTxMsgStyle,
+		styles.ErrMsgStyle, styles.InfoMsgStyle, serialLog, msglog.LogFormat(flags.LogFormat), 100)
+	serialMsglog.SetPort(settings.Port)
+
+	return pipeModel{
+		session:       session.New(port, settings),
+		msglog:        serialMsglog,
+		out:           out,
+		errOut:        errOut,
+		showTimestamp: flags.Timestamp,
+	}
+}
+
+func (m pipeModel) Init() tea.Cmd {
+	return tea.Batch(m.session.Init(), m.share.Init())
+}
+
+func (m pipeModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	m.session, cmd = m.session.Update(msg)
+	cmds = append(cmds, cmd)
+
+	m.msglog, cmd = m.msglog.Update(msg)
+	cmds = append(cmds, cmd)
+
+	m.share, cmd = m.share.Update(msg)
+	cmds = append(cmds, cmd)
+
+	switch msg := msg.(type) {
+	case events.SerialRxMsgReceived:
+		m.write(string(msg), true)
+
+	case events.SerialRxSplitMsg:
+		m.write(string(msg), false)
+		m.lineWritten = 0
+
+	case events.SerialRxPartialMsg:
+		m.write(string(msg), false)
+		m.lineWritten = len(msg)
+
+	case events.SendMsg:
+		m.pending++
+
+	case events.SerialTxMsg:
+		m.pending--
+		cmds = append(cmds, m.drain())
+
+	case *session.SendError:
+		fmt.Fprintln(m.errOut, "Error:", error(msg))
+		m.pending--
+		cmds = append(cmds, m.drain())
+
+	case events.ErrMsg:
+		fmt.Fprintln(m.errOut, "Error:", error(msg))
+
+	case events.InfoMsg:
+		fmt.Fprintln(m.errOut, string(msg))
+
+	case stdinClosedMsg:
+		m.eof = true
+		cmds = append(cmds, m.drain())
+
+	case pipeDrainedMsg:
+		return m, tea.Quit
+	}
+
+	return m, tea.Batch(cmds...)
+}
+
+// drain starts the drain time once stdin is closed and all lines are
+// written to the port.
+func (m pipeModel) drain() tea.Cmd {
+	if !m.eof || m.pending > 0 {
+		return nil
+	}
+	return tea.Tick(pipeDrainTime, func(time.Time) tea.Msg { return pipeDrainedMsg{} })
+}
+
+// write prints received data, the part already printed as partial message
+// is skipped.
+func (m *pipeModel) write(data string, complete bool) {
+	if !m.midLine && m.showTimestamp {
+		fmt.Fprintf(m.out, "[%s] ", time.Now().Format("15:04:05.000"))
+	}
+	m.midLine = true
+	if m.lineWritten < len(data) {
+		io.WriteString(m.out, data[m.lineWritten:])
+	}
+	if complete {
+		io.WriteString(m.out, "\n")
+		m.lineWritten = 0
+		m.midLine = false
+	}
+}
+
+func (m pipeModel) View() string {
+	return ""
+}
+
+// readStdin sends every line of stdin to the port.
+func readStdin(p *tea.Program, in io.Reader) {
+	r := bufio.NewReader(in)
+	for {
+		line, err := r.ReadString('\n')
+		if line != "" {
+			line = strings.TrimSuffix(strings.TrimSuffix(line, "\n"), "\r")
+			p.Send(events.SendMsg{Data: line})
+		}
+		if err != nil {
+			p.Send(stdinClosedMsg{})
+			return
+		}
+	}
+}
+
+// RunPipe forwards the lines of stdin to the port and prints the received
+// lines to stdout, until stdin is closed or a signal is received. After stdin
+// is closed, received data is printed for the drain time.
+func RunPipe(port *io.ReadWriteCloser, settings session.Settings, flags Flags, serialLog *log.Logger,
+	pty *session.Pty, shareServer *share.Server,
+) error {
+	m := newPipeModel(port, settings, flags, serialLog, os.Stdout, os.Stderr)
+	if pty != nil {
+		m.session.SetMirror(pty)
+	}
+	m.share = share.New(shareServer)
+	if shareServer != nil {
+		m.msglog.SetTextLog(log.New(shareServer, "", 0))
+	}
+	p := tea.NewProgram(m, tea.WithoutRenderer(), tea.WithInput(nil))
+	go readStdin(p, os.Stdin)
+
+	if _, err := p.Run(); err != nil && !errors.Is(err, tea.ErrInterrupted) {
+		return err
+	}
+	return nil
+}
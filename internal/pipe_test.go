@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+func TestPipeOutput(t *testing.T) {
+	var out, errOut bytes.Buffer
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := newPipeModel(&port, mockSettings, Flags{}, nil, &out, &errOut)
+
+	for _, msg := range []any{
+		events.SerialRxPartialMsg("log"),
+		events.SerialRxPartialMsg("login: "),
+		events.SerialRxMsgReceived("login: root"),
+		events.SerialRxSplitMsg("long "),
+		events.SerialRxMsgReceived("line"),
+		events.InfoMsg("Port reconnected"),
+	} {
+		model, _ := m.Update(msg)
+		m = model.(pipeModel)
+	}
+
+	if want := "login: root\nlong line\n"; out.String() != want {
+		t.Errorf("stdout = %q, want %q", out.String(), want)
+	}
+	if want := "Port reconnected\n"; errOut.String() != want {
+		t.Errorf("stderr = %q, want %q", errOut.String(), want)
+	}
+}
+
+// A line received in parts gets one timestamp.
+func TestPipeTimestamps(t *testing.T) {
+	var out bytes.Buffer
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := newPipeModel(&port, mockSettings, Flags{Timestamp: true}, nil, &out, io.Discard)
+
+	for _, msg := range []any{
+		events.SerialRxSplitMsg("long "),
+		events.SerialRxPartialMsg("li"),
+		events.SerialRxMsgReceived("line"),
+		events.SerialRxMsgReceived("next"),
+	} {
+		model, _ := m.Update(msg)
+		m = model.(pipeModel)
+	}
+
+	lines := strings.Split(strings.TrimSuffix(out.String(), "\n"), "\n")
+	if len(lines) != 2 || strings.Count(out.String(), "[") != 2 ||
+		!strings.HasSuffix(lines[0], "] long line") || !strings.HasSuffix(lines[1], "] next") {
+		t.Errorf("stdout = %q, want two lines with one timestamp each", out.String())
+	}
+}
+
+func TestPipeQuit(t *testing.T) {
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := newPipeModel(&port, mockSettings, Flags{}, nil, io.Discard, io.Discard)
+
+	model, _ := m.Update(events.SendMsg{Data: "a"})
+	m = model.(pipeModel)
+	if _, cmd := m.Update(stdinClosedMsg{}); cmd != nil {
+		t.Fatal("quit before the pending line was written")
+	}
+	model, _ = m.Update(stdinClosedMsg{})
+	m = model.(pipeModel)
+	if _, cmd := m.Update(events.SerialTxMsg{Data: "a"}); cmd == nil {
+		t.Error("no drain after the last line was written")
+	}
+}
+
+func TestPipeSendError(t *testing.T) {
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := newPipeModel(&port, mockSettings, Flags{}, nil, io.Discard, io.Discard)
+
+	model, _ := m.Update(events.SendMsg{Data: "a"})
+	m = model.(pipeModel)
+	model, _ = m.Update(stdinClosedMsg{})
+	m = model.(pipeModel)
+
+	// errors of the port are not the result of the send
+	if _, cmd := m.Update(events.ErrMsg(errors.New("read failed"))); cmd != nil {
+		t.Fatal("drain before the pending line was written")
+	}
+	if _, cmd := m.Update(events.ErrMsg(&session.SendError{Err: errors.New("write failed")})); cmd == nil {
+		t.Error("no drain after the last line failed")
+	}
+}
@@ -0,0 +1,83 @@
+package internal
+
+import (
+	"fmt"
+	"log"
+	"path/filepath"
+	"slices"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+// The serial log of the shown tab can be started and stopped during the
+// session with the record key, whether the session was started with -log or
+// not. Each recording goes into a new file, its start and stop are marked in
+// the log. Optionally the messages already in the message log are written
+// first.
+
+// toggleRecording starts or stops the serial log of the shown tab.
+func (m *model) toggleRecording(scrollback bool) tea.Cmd {
+	if m.logFile != nil {
+		return m.stopRecording()
+	}
+	return m.startRecording(scrollback)
+}
+
+// startRecording logs the shown tab into a new file.
+func (m *model) startRecording(scrollback bool) tea.Cmd {
+	settings := m.session.GetSettings()
+	path := tabLogPath(m.tabConfig.logDir, settings.Port)
+	f, closeLog, err := openSerialLog(path, m.tabConfig.logRotation, SerialLogHeader(m.tabConfig.logFormat, settings))
+	if err != nil {
+		return func() tea.Msg {
+			return events.ErrMsg(fmt.Errorf("recording not started: %v", err))
+		}
+	}
+	m.closeLogs = append(m.closeLogs, openLog{f: f, close: closeLog})
+
+	m.logFile = f
+	m.setSerialLog()
+	if scrollback {
+		m.msglog.LogScrollback()
+	}
+	m.msglog, _ = m.msglog.Update(events.InfoMsg("Recording started: " + path))
+	return nil
+}
+
+// stopRecording marks the end of the serial log of the shown tab and closes
+// it.
+func (m *model) stopRecording() tea.Cmd {
+	f := m.logFile
+	m.msglog, _ = m.msglog.Update(events.InfoMsg("Recording stopped: " + f.Name()))
+	m.logFile = nil
+	m.setSerialLog()
+	m.closeLogs = slices.DeleteFunc(m.closeLogs, func(l openLog) bool { return l.f == f })
+
+	if err := f.Close(); err != nil {
+		return func() tea.Msg {
+			return events.ErrMsg(err)
+		}
+	}
+	return nil
+}
+
+// setSerialLog passes the serial log of the shown tab to its message log.
+func (m *model) setSerialLog() {
+	if m.logFile == nil {
+		m.msglog.SetSerialLog(nil)
+		return
+	}
+	m.msglog.SetSerialLog(log.New(m.logFile, "", 0))
+}
+
+// logFileView returns the recording indicator with the current serial log
+// file of the shown tab and its size for the footer.
+func (m model) logFileView() string {
+	if m.logFile == nil {
+		return ""
+	}
+	return styles.FooterStyle.Render(" | ") + styles.RecordStyle.Render("● REC") +
+		styles.FooterStyle.Render(fmt.Sprintf(" %s %s", filepath.Base(m.logFile.Name()), formatSize(m.logFile.Size())))
+}
@@ -0,0 +1,153 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/styles"
+	"github.com/mahlburgc/teaterm/internal/triggers"
+)
+
+// A replay plays a JSONL serial log back in the first tab. The replay port
+// passes the recorded messages to the session, the replay model passes the
+// keys to the player and shows its state in the footer.
+
+// replayModel controls the replay, if the first tab replays a log.
+type replayModel struct {
+	port   *replayPort
+	status replayStatus
+}
+
+// newReplayModel creates the model for the port. For other ports than a
+// replay port, the model does nothing.
+func newReplayModel(port io.ReadWriteCloser) replayModel {
+	r, ok := port.(*replayPort)
+	if !ok {
+		return replayModel{}
+	}
+	return replayModel{port: r, status: r.status(0, r.speed, r.paused)}
+}
+
+// setReplay replays the log in the first tab, if the port is a replay port.
+// The messages are shown with the recorded time. Nothing can be sent during a
+// replay, so the replayed session has no triggers.
+func (m *model) setReplay(port io.ReadWriteCloser) {
+	m.replay = newReplayModel(port)
+	if m.replay.port == nil {
+		return
+	}
+	m.msglog.SetClock(m.replay.port.clock)
+	m.triggers = triggers.New(nil)
+}
+
+func (m replayModel) Init() tea.Cmd {
+	return m.waitForEvent()
+}
+
+// Matches reports whether the key is handled by the replay, while the
+// replay tab is shown. The port of a replay can not be closed or changed.
+func (m replayModel) Matches(msg tea.KeyMsg) bool {
+	return m.port != nil && key.Matches(msg, keymap.Default.ReplayPauseKey, keymap.Default.ReplayStepKey,
+		keymap.Default.ReplayFasterKey, keymap.Default.ReplaySlowerKey, keymap.Default.ReplayBackKey,
+		keymap.Default.ReplayForwardKey, keymap.Default.ToggleSessionKey, keymap.Default.SettingsKey)
+}
+
+func (m replayModel) Update(msg tea.Msg) (replayModel, tea.Cmd) {
+	if m.port == nil {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case replayStatusMsg:
+		m.status = replayStatus(msg)
+		return m, m.waitForEvent()
+
+	case tea.KeyMsg:
+		switch {
+		case key.Matches(msg, keymap.Default.ReplayPauseKey):
+			m.port.control(replayPause)
+		case key.Matches(msg, keymap.Default.ReplayStepKey):
+			m.port.control(replayStep)
+		case key.Matches(msg, keymap.Default.ReplayFasterKey):
+			m.port.control(replayFaster)
+		case key.Matches(msg, keymap.Default.ReplaySlowerKey):
+			m.port.control(replaySlower)
+		case key.Matches(msg, keymap.Default.ReplayBackKey):
+			m.port.control(replaySeekBack)
+		case key.Matches(msg, keymap.Default.ReplayForwardKey):
+			m.port.control(replaySeekForward)
+		case key.Matches(msg, keymap.Default.ToggleSessionKey, keymap.Default.SettingsKey):
+			return m, func() tea.Msg {
+				return events.InfoMsg("The port of a replay can not be changed")
+			}
+		}
+	}
+	return m, nil
+}
+
+func (m replayModel) waitForEvent() tea.Cmd {
+	if m.port == nil {
+		return nil
+	}
+	port := m.port
+	return func() tea.Msg {
+		msg, _ := port.waits.Wait(func() tea.Msg {
+			select {
+			case msg := <-port.events:
+				return msg
+			case <-port.ctx.Done():
+				return nil
+			}
+		})
+		return msg
+	}
+}
+
+// View returns the state of the replay for the footer.
+func (m replayModel) View() string {
+	if m.port == nil {
+		return ""
+	}
+	s := m.status
+	state := "▶"
+	switch {
+	case s.pos == s.total:
+		state = "done"
+	case s.paused:
+		state = "paused"
+	}
+	speed := strconv.FormatFloat(s.speed, 'g', 3, 64)
+	return styles.FooterStyle.Render(fmt.Sprintf(" | replay %s %s/%s %sx",
+		state, formatReplayTime(s.at), formatReplayTime(s.length), speed))
+}
+
+// formatReplayTime formats a recorded time as hh:mm:ss.
+func formatReplayTime(d time.Duration) string {
+	return fmt.Sprintf("%02d:%02d:%02d", int(d.Hours()), int(d.Minutes())%60, int(d.Seconds())%60)
+}
+
+// updateReplay handles a recorded message of the replay. The message is
+// passed to the first tab, it is the replayed session.
+func (m model) updateReplay(msg replayMsg) (tea.Model, tea.Cmd) {
+	m.replay.port.ack()
+	wait := m.replay.waitForEvent()
+
+	if _, ok := msg.msg.(replayClearMsg); ok {
+		switch i := m.tabIndex(firstTab); {
+		case i == m.active:
+			m.msglog.Clear()
+		case i >= 0:
+			m.tabs[i].msglog.Clear()
+		}
+		return m, wait
+	}
+
+	model, cmd := m.Update(tabMsg{id: firstTab, msg: msg.msg})
+	return model, tea.Batch(cmd, wait)
+}
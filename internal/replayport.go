@@ -0,0 +1,390 @@
+package internal
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/handover"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+)
+
+const (
+	// time the replay jumps with a seek
+	replaySeekStep = 10 * time.Second
+	// time to wait for a replayed message to be handled, before the replay
+	// goes on anyway, e.g. if the port was closed
+	replayAckTimeout = time.Second
+	minReplaySpeed   = 1.0 / 64
+	maxReplaySpeed   = 1024
+)
+
+// replayCtrl is a command of the user to the player.
+type replayCtrl int
+
+const (
+	replayPause replayCtrl = iota // pause or resume
+	replayStep                    // replay the next message and pause
+	replayFaster
+	replaySlower
+	replaySeekBack
+	replaySeekForward
+)
+
+type (
+	// A recorded message, like received data, a sent message or an info.
+	// The TUI passes it to the replayed session.
+	replayMsg struct {
+		msg tea.Msg
+	}
+	// Sent as recorded message if the replay starts again, e.g. after a seek
+	// back. The message log is cleared.
+	replayClearMsg struct{}
+	// Sent when the state of the replay changed.
+	replayStatusMsg replayStatus
+)
+
+// replayStatus is the state of the replay shown in the footer.
+type replayStatus struct {
+	pos    int           // number of replayed messages
+	total  int           // number of messages
+	at     time.Duration // recorded time of the last replayed message since the start
+	length time.Duration
+	speed  float64
+	paused bool
+}
+
+// replayRecord is a message of the replayed log.
+type replayRecord struct {
+	time time.Time
+	msg  tea.Msg // received or sent message, info or error
+}
+
+// replayPort plays a JSONL serial log back with the recorded timing.
+// The recorded messages are passed to the TUI, received frames as they were
+// recorded, whatever framing the session had. The filter and the triggers
+// work like in a live session. Nothing is read from the port. The player
+// waits until each message is handled, so the order of the messages is kept
+// at every speed.
+type replayPort struct {
+	records []replayRecord
+	speed   float64 // speed and state on start
+	paused  bool
+
+	// recorded messages and status changes for the tea program
+	events chan tea.Msg
+	ctrl   chan replayCtrl
+	acks   chan struct{}
+	// the events go to the program restarted after the editor
+	waits handover.Latest[tea.Msg]
+
+	mu  sync.Mutex // guards now
+	now time.Time  // recorded time of the last replayed message
+
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// OpenReplayPort opens the JSONL serial log at path for the replay with the
+// speed factor. A paused replay is started with the step or pause keys.
+func OpenReplayPort(path string, speed float64, paused bool) (io.ReadWriteCloser, error) {
+	records, err := loadReplay(path)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%s: no messages to replay", path)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	r := &replayPort{
+		records: records,
+		speed:   min(max(speed, minReplaySpeed), maxReplaySpeed),
+		paused:  paused,
+		events:  make(chan tea.Msg),
+		ctrl:    make(chan replayCtrl, 16),
+		acks:    make(chan struct{}, 1),
+		now:     records[0].time,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+	go r.play()
+	return r, nil
+}
+
+// loadReplay reads the messages of a JSONL serial log.
+func loadReplay(path string) ([]replayRecord, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var records []replayRecord
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		var record msglog.LogRecord
+		if err := json.Unmarshal([]byte(line), &record); err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		r, err := newReplayRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", path, n, err)
+		}
+		records = append(records, r)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return records, nil
+}
+
+// newReplayRecord converts a record of the log file into the message it was
+// logged for.
+func newReplayRecord(record msglog.LogRecord) (replayRecord, error) {
+	data, err := record.Data()
+	if err != nil {
+		return replayRecord{}, fmt.Errorf("invalid raw data: %v", err)
+	}
+
+	r := replayRecord{time: record.Time}
+	switch record.Dir {
+	case "rx":
+		// the framing delimiter is not logged, the frame is replayed as one
+		// message
+		r.msg = events.SerialRxMsgReceived(data)
+	case "tx":
+		// raw data is followed by the line ending, text is the data only
+		raw := string(data)
+		msg := strings.TrimRight(raw, "\r\n")
+		if strings.HasPrefix(raw, record.Text) {
+			msg = record.Text
+		}
+		lineEnding := raw[len(msg):]
+		if record.Source != "" {
+			r.msg = events.ForwardedTxMsg{Data: msg, LineEnding: lineEnding, Source: record.Source}
+		} else {
+			r.msg = events.SerialTxMsg{Data: msg, LineEnding: lineEnding}
+		}
+	case "info":
+		r.msg = events.InfoMsg(record.Text)
+	case "err":
+		r.msg = events.ErrMsg(errors.New(record.Text))
+	default:
+		return replayRecord{}, fmt.Errorf("invalid dir %q", record.Dir)
+	}
+	return r, nil
+}
+
+// play is the player goroutine. It owns the position, speed and pause state
+// and replays the messages until the port is closed.
+func (r *replayPort) play() {
+	pos, speed, paused := 0, r.speed, r.paused
+	left := r.gap(pos) // recorded time until the next message
+
+	for {
+		if !r.send(replayStatusMsg(r.status(pos, speed, paused))) {
+			return
+		}
+
+		var timer *time.Timer
+		var due <-chan time.Time
+		start := time.Now()
+		if !paused && pos < len(r.records) {
+			timer = time.NewTimer(time.Duration(float64(left) / speed))
+			due = timer.C
+		}
+
+		select {
+		case <-due:
+			if !r.replay(pos) {
+				return
+			}
+			pos++
+			left = r.gap(pos)
+
+		case c := <-r.ctrl:
+			if timer != nil {
+				timer.Stop()
+				left = max(left-time.Duration(float64(time.Since(start))*speed), 0)
+			}
+			switch c {
+			case replayPause:
+				paused = !paused
+			case replayStep:
+				paused = true
+				if pos < len(r.records) {
+					if !r.replay(pos) {
+						return
+					}
+					pos++
+					left = r.gap(pos)
+				}
+			case replayFaster:
+				speed = min(speed*2, maxReplaySpeed)
+			case replaySlower:
+				speed = max(speed/2, minReplaySpeed)
+			case replaySeekBack, replaySeekForward:
+				var ok bool
+				if pos, left, ok = r.seek(pos, c == replaySeekForward); !ok {
+					return
+				}
+			}
+
+		case <-r.ctx.Done():
+			return
+		}
+	}
+}
+
+// seek jumps by the seek step from the message at pos. The messages up to
+// the new position are replayed at once. A seek back replays from the start.
+// It returns the new position and the time until the next message.
+func (r *replayPort) seek(pos int, forward bool) (int, time.Duration, bool) {
+	target := r.offset(pos-1) + replaySeekStep
+	if !forward {
+		target = max(r.offset(pos-1)-replaySeekStep, 0)
+		if !r.deliver(replayRecord{msg: replayClearMsg{}}) {
+			return 0, 0, false
+		}
+		pos = 0
+	}
+
+	for pos < len(r.records) && r.offset(pos) <= target {
+		if !r.replay(pos) {
+			return 0, 0, false
+		}
+		pos++
+	}
+	if pos == len(r.records) {
+		return pos, 0, true
+	}
+	return pos, r.offset(pos) - target, true
+}
+
+// offset returns the recorded time of the message at pos since the start.
+func (r *replayPort) offset(pos int) time.Duration {
+	if pos < 0 {
+		return 0
+	}
+	return r.records[pos].time.Sub(r.records[0].time)
+}
+
+// gap returns the recorded time between the message at pos and the one
+// before.
+func (r *replayPort) gap(pos int) time.Duration {
+	if pos == 0 || pos >= len(r.records) {
+		return 0
+	}
+	return max(r.offset(pos)-r.offset(pos-1), 0)
+}
+
+func (r *replayPort) status(pos int, speed float64, paused bool) replayStatus {
+	return replayStatus{
+		pos:    pos,
+		total:  len(r.records),
+		at:     r.offset(pos - 1),
+		length: r.offset(len(r.records) - 1),
+		speed:  speed,
+		paused: paused,
+	}
+}
+
+// replay replays the message at pos.
+func (r *replayPort) replay(pos int) bool {
+	r.mu.Lock()
+	r.now = r.records[pos].time
+	r.mu.Unlock()
+	return r.deliver(r.records[pos])
+}
+
+// deliver passes the message to the TUI and waits until it is handled. It
+// returns false if the port was closed.
+func (r *replayPort) deliver(record replayRecord) bool {
+	// an ack of a message handled after the timeout
+	select {
+	case <-r.acks:
+	default:
+	}
+
+	if !r.send(replayMsg{msg: record.msg}) {
+		return false
+	}
+
+	timeout := time.NewTimer(replayAckTimeout)
+	defer timeout.Stop()
+	select {
+	case <-r.acks:
+	case <-timeout.C:
+	case <-r.ctx.Done():
+		return false
+	}
+	return true
+}
+
+// send passes a message to the tea program.
+func (r *replayPort) send(msg tea.Msg) bool {
+	select {
+	case r.events <- msg:
+		return true
+	case <-r.ctx.Done():
+		return false
+	}
+}
+
+// ack tells the player that the last replayed message is handled.
+func (r *replayPort) ack() {
+	select {
+	case r.acks <- struct{}{}:
+	default:
+	}
+}
+
+// control passes a command to the player. Commands are dropped if the player
+// does not keep up, the tea program must not block.
+func (r *replayPort) control(c replayCtrl) {
+	select {
+	case r.ctrl <- c:
+	default:
+	}
+}
+
+// clock returns the recorded time of the last replayed message, it is the
+// time of the messages shown.
+func (r *replayPort) clock() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.now
+}
+
+// Read blocks until the port is closed, the received data is replayed as
+// messages.
+func (r *replayPort) Read(p []byte) (int, error) {
+	<-r.ctx.Done()
+	return 0, io.EOF
+}
+
+// Write fails, nothing can be sent to a recorded session.
+func (r *replayPort) Write(p []byte) (int, error) {
+	return 0, errors.New("sending is not possible during a replay")
+}
+
+// Close stops the player.
+func (r *replayPort) Close() error {
+	r.cancel()
+	return nil
+}
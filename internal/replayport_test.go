@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	settings "github.com/mahlburgc/teaterm/internal/settings-overlay"
+	"github.com/mahlburgc/teaterm/internal/triggers"
+)
+
+// writeReplayLog writes a JSONL serial log of a short session: a banner, a
+// sent command with its answer and an info 20 s later.
+func writeReplayLog(t *testing.T) string {
+	t.Helper()
+	log := `{"time":"2026-10-16T10:00:00Z","dir":"rx","port":"/dev/ttyUSB0","raw":"Ym9vdA==","text":"boot"}
+{"time":"2026-10-16T10:00:01Z","dir":"tx","port":"/dev/ttyUSB0","raw":"aW5mbw0K","text":"info"}
+
+{"time":"2026-10-16T10:00:01.1Z","dir":"rx","port":"/dev/ttyUSB0","raw":"djEuMA==","text":"v1.0"}
+{"time":"2026-10-16T10:00:21Z","dir":"info","raw":"Ynll","text":"bye"}
+`
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	if err := os.WriteFile(path, []byte(log), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// nextReplayMsg returns the next recorded message passed to the TUI and
+// acks it. Status messages are skipped.
+func nextReplayMsg(t *testing.T, r *replayPort) tea.Msg {
+	t.Helper()
+	for {
+		select {
+		case msg := <-r.events:
+			if rec, ok := msg.(replayMsg); ok {
+				r.ack()
+				return rec.msg
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatal("no replayed message")
+			return nil
+		}
+	}
+}
+
+// nextReplayStatus returns the next status of the player.
+func nextReplayStatus(t *testing.T, r *replayPort) replayStatus {
+	t.Helper()
+	for {
+		select {
+		case msg := <-r.events:
+			if status, ok := msg.(replayStatusMsg); ok {
+				return replayStatus(status)
+			}
+			t.Fatalf("unexpected message %#v", msg)
+		case <-time.After(2 * time.Second):
+			t.Fatal("no replay status")
+			return replayStatus{}
+		}
+	}
+}
+
+func TestReplayPort(t *testing.T) {
+	port, err := OpenReplayPort(writeReplayLog(t), 100, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	r := port.(*replayPort)
+
+	if got := nextReplayMsg(t, r); got != events.SerialRxMsgReceived("boot") {
+		t.Errorf("rx = %#v, want %q", got, "boot")
+	}
+
+	want := events.SerialTxMsg{Data: "info", LineEnding: "\r\n"}
+	if got := nextReplayMsg(t, r); got != want {
+		t.Errorf("tx = %#v, want %#v", got, want)
+	}
+	if got := nextReplayMsg(t, r); got != events.SerialRxMsgReceived("v1.0") {
+		t.Errorf("rx = %#v, want %q", got, "v1.0")
+	}
+	if got := r.clock(); !got.Equal(time.Date(2026, 10, 16, 10, 0, 1, 100e6, time.UTC)) {
+		t.Errorf("clock = %v, want the recorded time of the last message", got)
+	}
+	if got := nextReplayMsg(t, r); got != events.InfoMsg("bye") {
+		t.Errorf("info = %#v, want %q", got, "bye")
+	}
+
+	if _, err := port.Write([]byte("info\r\n")); err == nil {
+		t.Error("write succeeded, want an error")
+	}
+}
+
+func TestReplayStepAndSeek(t *testing.T) {
+	port, err := OpenReplayPort(writeReplayLog(t), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	r := port.(*replayPort)
+
+	if s := nextReplayStatus(t, r); s.pos != 0 || s.total != 4 || !s.paused || s.length != 21*time.Second {
+		t.Fatalf("status = %+v, want paused at the start of 4 messages of 21 s", s)
+	}
+
+	r.control(replayStep)
+	if got := nextReplayMsg(t, r); got != events.SerialRxMsgReceived("boot") {
+		t.Errorf("rx = %#v, want %q", got, "boot")
+	}
+	if s := nextReplayStatus(t, r); s.pos != 1 || !s.paused {
+		t.Errorf("status = %+v, want paused after the first message", s)
+	}
+
+	// the messages up to 10 s are replayed at once
+	r.control(replaySeekForward)
+	if _, ok := nextReplayMsg(t, r).(events.SerialTxMsg); !ok {
+		t.Error("seek did not replay the sent message")
+	}
+	nextReplayMsg(t, r)
+	if s := nextReplayStatus(t, r); s.pos != 3 || s.at != 1100*time.Millisecond {
+		t.Errorf("status = %+v, want 3 messages replayed", s)
+	}
+
+	// a seek back clears the log and replays from the start
+	r.control(replaySeekBack)
+	if _, ok := nextReplayMsg(t, r).(replayClearMsg); !ok {
+		t.Error("seek back did not clear the log")
+	}
+	if got := nextReplayMsg(t, r); got != events.SerialRxMsgReceived("boot") {
+		t.Errorf("rx = %#v, want %q", got, "boot")
+	}
+	if s := nextReplayStatus(t, r); s.pos != 1 {
+		t.Errorf("status = %+v, want the first message replayed", s)
+	}
+}
+
+func TestReplayInvalidLog(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	os.WriteFile(path, []byte(`{"time":"2026-10-16T10:00:00Z","dir":"up","raw":"","text":""}`+"\n"), 0o644)
+	if _, err := OpenReplayPort(path, 1, false); err == nil {
+		t.Error("invalid dir accepted")
+	}
+}
+
+// Frames recorded with another framing than lf are replayed unchanged.
+func TestReplayFrames(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "session.jsonl")
+	os.WriteFile(path, []byte(`{"time":"2026-10-16T10:00:00Z","dir":"rx","raw":"b25lCnR3bw==","text":"one\ntwo"}`+"\n"), 0o644)
+	port, err := OpenReplayPort(path, 1, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	if got := nextReplayMsg(t, port.(*replayPort)); got != events.SerialRxMsgReceived("one\ntwo") {
+		t.Errorf("rx = %#v, want one frame %q", got, "one\ntwo")
+	}
+}
+
+// The port of other tabs can be changed during a replay.
+func TestReplayKeysOnReplayTab(t *testing.T) {
+	zone.NewGlobal()
+	port, err := OpenReplayPort(writeReplayLog(t), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m.setReplay(port)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyCtrlS}, nil, 0)
+	if m.showSettings {
+		t.Fatal("settings dialog opened on the replay tab")
+	}
+
+	second := mockSettings
+	second.Port = "second"
+	nm, _ := m.Update(settings.NewTabMsg{Settings: second})
+	m = nm.(model)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyCtrlS}, nil, 0)
+	if !m.showSettings {
+		t.Error("settings dialog not opened on another tab")
+	}
+}
+
+// After the editor restarts the program, the recorded messages go to the new
+// program, none is lost with the old one.
+func TestReplayRestart(t *testing.T) {
+	port, err := OpenReplayPort(writeReplayLog(t), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	m := newReplayModel(port)
+	if _, ok := m.Init()().(replayStatusMsg); !ok {
+		t.Fatal("no status on start")
+	}
+
+	old := make(chan tea.Msg, 1)
+	go func() { old <- m.waitForEvent()() }()
+	time.Sleep(20 * time.Millisecond)
+	restarted := make(chan tea.Msg, 1)
+	go func() { restarted <- m.Init()() }()
+	time.Sleep(20 * time.Millisecond)
+
+	m.port.control(replayStep)
+	start := time.Now()
+	select {
+	case msg := <-restarted:
+		if rec, ok := msg.(replayMsg); !ok || rec.msg != events.SerialRxMsgReceived("boot") {
+			t.Errorf("new program got %#v, want the first message", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new program got no message")
+	}
+	if msg := <-old; msg != nil {
+		t.Errorf("old program got %#v", msg)
+	}
+	m.port.ack()
+
+	if _, ok := m.waitForEvent()().(replayStatusMsg); !ok || time.Since(start) >= replayAckTimeout {
+		t.Error("replay did not go on after the ack")
+	}
+}
+
+// Triggers do not answer replayed messages, nothing can be sent.
+func TestReplayNoTriggers(t *testing.T) {
+	port, err := OpenReplayPort(writeReplayLog(t), 1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m.triggers = triggers.New([]triggers.Trigger{{Match: "boot", Send: "x"}})
+	m.setReplay(port)
+
+	if _, cmd := m.triggers.Update(events.SerialRxMsgReceived("boot")); cmd != nil {
+		t.Error("trigger fired on a replayed message")
+	}
+}
@@ -0,0 +1,326 @@
+package script
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+const (
+	// maximum number of received bytes kept for expect
+	maxRxBuffer = 64 * 1024
+	// tag of the sent messages, the messages sent meanwhile by the user are
+	// not the result of a send
+	sendTag = "script"
+)
+
+type state int
+
+const (
+	idle state = iota
+	running
+	sending   // wait until the data is written
+	expecting // wait for a match or the timeout
+	sleeping
+	done
+)
+
+type (
+	// Sent to continue the script after a sleep.
+	wakeUpMsg struct{ id int }
+	// Sent after the timeout of an expect.
+	timeoutMsg struct{ id int }
+)
+
+// DoneMsg is sent when the script ended. Err is nil if the script succeeded.
+type DoneMsg struct {
+	Err error
+}
+
+type Model struct {
+	script  *Script
+	state   state
+	pc      int // index of the next command
+	vars    map[string]string
+	loops   map[int]int // remaining iterations of the running loops
+	timeout time.Duration
+	// expect of the current command and the id of its timeout or sleep
+	re *regexp.Regexp
+	id int
+	rx rxBuffer
+}
+
+// New creates a runner for the script. A nil script creates an idle runner,
+// that ignores all messages.
+func New(script *Script) (m Model) {
+	m.script = script
+	m.vars = make(map[string]string)
+	m.loops = make(map[int]int)
+	m.timeout = DefaultTimeout
+	return m
+}
+
+// Running reports whether the script was started and has not ended yet.
+func (m Model) Running() bool {
+	return m.state != idle && m.state != done
+}
+
+// Start runs the script. A script is only run once.
+func (m *Model) Start() tea.Cmd {
+	if m.script == nil || m.state != idle {
+		return nil
+	}
+	m.state = running
+	name := m.script.Name
+	infoCmd := func() tea.Msg {
+		return events.InfoMsg(fmt.Sprintf("Running script %s", name))
+	}
+	return tea.Batch(infoCmd, m.exec())
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	if !m.Running() {
+		return m, nil
+	}
+
+	switch msg := msg.(type) {
+	case events.SerialRxMsgReceived:
+		m.rx.addLine(string(msg))
+		return m, m.expect()
+
+	case events.SerialRxPartialMsg:
+		m.rx.setPartial(string(msg))
+		return m, m.expect()
+
+	case events.SerialRxSplitMsg:
+		m.rx.addSplit(string(msg))
+		return m, m.expect()
+
+	case events.SerialTxMsg:
+		if m.state == sending && msg.Tag == sendTag {
+			m.state = running
+			return m, m.exec()
+		}
+
+	case *session.SendError:
+		if m.state == sending && msg.Tag == sendTag {
+			return m, m.fail(fmt.Errorf("send failed: %v", msg.Err))
+		}
+
+	case wakeUpMsg:
+		if m.state == sleeping && msg.id == m.id {
+			m.state = running
+			return m, m.exec()
+		}
+
+	case timeoutMsg:
+		if m.state == expecting && msg.id == m.id {
+			return m, m.fail(fmt.Errorf("expect %q timed out", m.re.String()))
+		}
+	}
+
+	return m, nil
+}
+
+// exec runs the commands up to the next one that has to wait.
+func (m *Model) exec() tea.Cmd {
+	var cmds []tea.Cmd
+
+	for m.state == running && m.pc < len(m.script.instrs) {
+		in := m.script.instrs[m.pc]
+		m.pc++
+
+		switch in.op {
+		case opSend:
+			data, mode := m.expand(in.args[0]), in.mode
+			m.state = sending
+			cmds = append(cmds, func() tea.Msg {
+				return events.SendMsg{Data: data, Mode: mode, Automated: true, Tag: sendTag}
+			})
+
+		case opExpect:
+			re, err := regexp.Compile(m.expand(in.args[0]))
+			if err != nil {
+				return m.fail(err)
+			}
+			timeout := m.timeout
+			if len(in.args) == 2 {
+				timeout, _ = parseDuration(in.args[1])
+			}
+			m.re = re
+			if m.match() {
+				// already received
+				continue
+			}
+			m.state = expecting
+			m.id++
+			id := m.id
+			cmds = append(cmds, tea.Tick(timeout, func(time.Time) tea.Msg { return timeoutMsg{id: id} }))
+
+		case opSleep:
+			d, _ := parseDuration(in.args[0])
+			m.state = sleeping
+			m.id++
+			id := m.id
+			cmds = append(cmds, tea.Tick(d, func(time.Time) tea.Msg { return wakeUpMsg{id: id} }))
+
+		case opTimeout:
+			m.timeout, _ = parseDuration(in.args[0])
+
+		case opSet:
+			m.vars[in.args[0]] = m.expand(in.args[1])
+
+		case opLoop:
+			loop := m.pc - 1
+			if _, ok := m.loops[loop]; !ok {
+				n, err := parseCount(m.expand(in.args[0]))
+				if err != nil {
+					return m.fail(err)
+				}
+				m.loops[loop] = n
+			}
+			if m.loops[loop] == 0 {
+				// finished, an outer loop starts it again from the beginning
+				delete(m.loops, loop)
+				m.pc = in.jump + 1
+			} else {
+				m.loops[loop]--
+			}
+
+		case opEnd:
+			m.pc = in.jump
+
+		case opEcho:
+			text := m.expand(in.args[0])
+			cmds = append(cmds, func() tea.Msg { return events.InfoMsg(text) })
+
+		case opFail:
+			msg := m.expand(in.args[0])
+			if msg == "" {
+				msg = "failed"
+			}
+			return tea.Batch(append(cmds, m.fail(errors.New(msg)))...)
+		}
+	}
+
+	if m.state == running {
+		m.state = done
+		cmds = append(cmds,
+			func() tea.Msg { return events.InfoMsg("Script finished") },
+			func() tea.Msg { return DoneMsg{} })
+	}
+	return tea.Batch(cmds...)
+}
+
+// expect continues the script if the received data matches the current
+// expect.
+func (m *Model) expect() tea.Cmd {
+	if m.state != expecting || !m.match() {
+		return nil
+	}
+	m.state = running
+	return m.exec()
+}
+
+// match checks the received data for the current expect. The capture groups
+// of a match are stored as variables ${0}, ${1}, ... and the data up to the
+// end of the match is consumed.
+func (m *Model) match() bool {
+	text := m.rx.text()
+	loc := m.re.FindStringSubmatchIndex(text)
+	if loc == nil {
+		return false
+	}
+
+	for i := 0; i < len(loc)/2; i++ {
+		group := ""
+		if loc[2*i] >= 0 {
+			group = text[loc[2*i]:loc[2*i+1]]
+		}
+		m.vars[strconv.Itoa(i)] = group
+	}
+	m.rx.consume(loc[1])
+	return true
+}
+
+// fail ends the script with the error of the current command.
+func (m *Model) fail(err error) tea.Cmd {
+	line := m.script.instrs[m.pc-1].line
+	m.state = done
+	err = fmt.Errorf("%s:%d: %v", m.script.Name, line, err)
+	return tea.Batch(
+		func() tea.Msg { return events.ErrMsg(err) },
+		func() tea.Msg { return DoneMsg{Err: err} })
+}
+
+// expand replaces the variables in the argument.
+func (m Model) expand(arg string) string {
+	return varPattern.ReplaceAllStringFunc(arg, func(v string) string {
+		return m.vars[v[2:len(v)-1]]
+	})
+}
+
+// rxBuffer holds the received data, that was not consumed by an expect.
+// Complete lines end with "\n". Unterminated data is sent as partial message
+// first and again as part of the complete line, so the bytes of the current
+// line consumed by an expect are skipped.
+type rxBuffer struct {
+	data    string
+	partial string
+	skip    int
+}
+
+// cut removes the consumed bytes from the start of the current line.
+func (b *rxBuffer) cut(s string) string {
+	n := min(b.skip, len(s))
+	b.skip -= n
+	return s[n:]
+}
+
+func (b *rxBuffer) addLine(s string) {
+	b.data += b.cut(s) + "\n"
+	b.partial = ""
+	b.skip = 0
+	b.trim()
+}
+
+// addSplit adds a part of a long line. The data following it is part of the
+// same line.
+func (b *rxBuffer) addSplit(s string) {
+	b.data += b.cut(s)
+	b.partial = ""
+	b.skip = 0
+	b.trim()
+}
+
+func (b *rxBuffer) setPartial(s string) {
+	b.partial = s[min(b.skip, len(s)):]
+}
+
+func (b *rxBuffer) text() string {
+	return b.data + b.partial
+}
+
+// consume removes the first n bytes of the text.
+func (b *rxBuffer) consume(n int) {
+	if n <= len(b.data) {
+		b.data = b.data[n:]
+		return
+	}
+	n -= len(b.data)
+	b.data = ""
+	b.partial = b.partial[n:]
+	b.skip += n
+}
+
+func (b *rxBuffer) trim() {
+	if len(b.data) > maxRxBuffer {
+		b.data = b.data[len(b.data)-maxRxBuffer:]
+	}
+}
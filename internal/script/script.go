@@ -0,0 +1,244 @@
+// Package script runs send/expect scripts against the session port.
+//
+// A script has one command per line, empty lines and lines starting with #
+// are ignored:
+//
+//	timeout 10s               default timeout of the following expects
+//	send root                 send text followed by the line ending
+//	sendraw \x03              send escaped bytes, no line ending
+//	sendhex 01 0A FF          send hex bytes, no line ending
+//	expect "login: " 5s       wait for a regular expression in the received data
+//	sleep 500ms               wait
+//	set ip ${1}               set a variable, ${1} is the first capture group
+//	                          of the last expect, ${0} the whole match
+//	loop 3                    repeat the commands up to the matching end
+//	end
+//	echo rebooting ${ip}      show a message in the message log
+//	fail no ip address        stop the script with an error
+//
+// Variables are used as ${name} in all arguments.
+package script
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+// default timeout of expect
+const DefaultTimeout = 10 * time.Second
+
+type op int
+
+const (
+	opSend op = iota
+	opExpect
+	opSleep
+	opSet
+	opLoop
+	opEnd
+	opEcho
+	opFail
+	opTimeout
+)
+
+var ops = map[string]op{
+	"send":    opSend,
+	"sendraw": opSend,
+	"sendhex": opSend,
+	"expect":  opExpect,
+	"sleep":   opSleep,
+	"set":     opSet,
+	"loop":    opLoop,
+	"end":     opEnd,
+	"echo":    opEcho,
+	"fail":    opFail,
+	"timeout": opTimeout,
+}
+
+type instr struct {
+	op   op
+	line int
+	// the arguments, variables are expanded when the command runs
+	args []string
+	mode events.InputMode // send mode
+	// index of the matching end of a loop and of the loop of an end
+	jump int
+}
+
+// Script is a parsed script.
+type Script struct {
+	Name   string
+	instrs []instr
+}
+
+var (
+	varPattern  = regexp.MustCompile(`\$\{(\w+)\}`)
+	namePattern = regexp.MustCompile(`^\w+$`)
+)
+
+// Load reads and parses the script file.
+func Load(path string) (*Script, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var lines []string
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return Parse(path, lines)
+}
+
+// Parse parses the lines of a script. The name is used in error messages.
+func Parse(name string, lines []string) (*Script, error) {
+	s := &Script{Name: name}
+	var loops []int
+
+	for i, text := range lines {
+		line := i + 1
+		text = strings.TrimSpace(text)
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		cmd, rest, _ := strings.Cut(text, " ")
+		rest = strings.TrimSpace(rest)
+		o, ok := ops[cmd]
+		if !ok {
+			return nil, fmt.Errorf("%s:%d: unknown command %q", name, line, cmd)
+		}
+
+		in := instr{op: o, line: line}
+		var err error
+		switch o {
+		case opSend:
+			in.args = []string{unquote(rest)}
+			in.mode = map[string]events.InputMode{
+				"send": events.TextMode, "sendraw": events.EscapeMode, "sendhex": events.HexMode,
+			}[cmd]
+			if !varPattern.MatchString(rest) {
+				_, err = session.EncodeInput(in.args[0], in.mode)
+			}
+
+		case opExpect:
+			in.args, err = splitArgs(rest)
+			if err == nil && (len(in.args) < 1 || len(in.args) > 2) {
+				err = fmt.Errorf("usage: expect <regex> [timeout]")
+			}
+			if err == nil && !varPattern.MatchString(in.args[0]) {
+				_, err = regexp.Compile(in.args[0])
+			}
+			if err == nil && len(in.args) == 2 {
+				_, err = parseDuration(in.args[1])
+			}
+
+		case opSleep, opTimeout:
+			in.args = []string{rest}
+			_, err = parseDuration(rest)
+
+		case opSet:
+			varName, value, _ := strings.Cut(rest, " ")
+			if !namePattern.MatchString(varName) {
+				err = fmt.Errorf("usage: set <name> <value>")
+			}
+			in.args = []string{varName, unquote(strings.TrimSpace(value))}
+
+		case opLoop:
+			in.args = []string{rest}
+			if !varPattern.MatchString(rest) {
+				_, err = parseCount(rest)
+			}
+			loops = append(loops, len(s.instrs))
+
+		case opEnd:
+			if len(loops) == 0 {
+				err = fmt.Errorf("end without loop")
+				break
+			}
+			in.jump = loops[len(loops)-1]
+			loops = loops[:len(loops)-1]
+			s.instrs[in.jump].jump = len(s.instrs)
+
+		case opEcho, opFail:
+			in.args = []string{unquote(rest)}
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %v", name, line, err)
+		}
+
+		s.instrs = append(s.instrs, in)
+	}
+
+	if len(loops) > 0 {
+		return nil, fmt.Errorf("%s:%d: loop without end", name, s.instrs[loops[0]].line)
+	}
+	return s, nil
+}
+
+// splitArgs splits the arguments at spaces. Arguments with spaces are
+// enclosed in double quotes, \" is a quote inside of them. Other backslashes
+// are kept, e.g. for regular expressions.
+func splitArgs(s string) ([]string, error) {
+	var args []string
+	for s = strings.TrimSpace(s); s != ""; s = strings.TrimSpace(s) {
+		if s[0] != '"' {
+			arg, rest, _ := strings.Cut(s, " ")
+			args = append(args, arg)
+			s = rest
+			continue
+		}
+
+		end := 1
+		for end < len(s) && s[end] != '"' {
+			if s[end] == '\\' {
+				end++
+			}
+			end++
+		}
+		if end >= len(s) {
+			return nil, fmt.Errorf("missing closing quote")
+		}
+		args = append(args, unquote(s[:end+1]))
+		s = s[end+1:]
+	}
+	return args, nil
+}
+
+// unquote removes the double quotes around an argument.
+func unquote(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		return strings.ReplaceAll(s[1:len(s)-1], `\"`, `"`)
+	}
+	return s
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	d, err := time.ParseDuration(s)
+	if err != nil || d < 0 {
+		return 0, fmt.Errorf("invalid duration %q", s)
+	}
+	return d, nil
+}
+
+func parseCount(s string) (int, error) {
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid loop count %q", s)
+	}
+	return n, nil
+}
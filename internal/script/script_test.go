@@ -0,0 +1,178 @@
+package script
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+// device answers the sent data with received messages.
+type device func(data string) []tea.Msg
+
+// runScript runs the script against the device and returns the sent data
+// joined by "|" and the result of the script.
+func runScript(t *testing.T, lines string, dev device) (string, error) {
+	t.Helper()
+
+	s, err := Parse("test", strings.Split(lines, "\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	m := New(s)
+	var sent []string
+	var result *DoneMsg
+
+	// Commands run concurrently like in a tea program, pending timeouts of
+	// earlier expects must not delay the script.
+	results := make(chan tea.Msg, 100)
+	var run func(cmd tea.Cmd)
+	run = func(cmd tea.Cmd) {
+		if cmd != nil {
+			go func() { results <- cmd() }()
+		}
+	}
+
+	run(m.Start())
+	var msgs []tea.Msg
+	for result == nil {
+		if len(msgs) == 0 {
+			select {
+			case msg := <-results:
+				msgs = append(msgs, msg)
+			case <-time.After(time.Second):
+				t.Fatalf("script did not end, sent %q", sent)
+			}
+		}
+
+		msg := msgs[0]
+		msgs = msgs[1:]
+		switch msg := msg.(type) {
+		case tea.BatchMsg:
+			for _, cmd := range msg {
+				run(cmd)
+			}
+			continue
+		case DoneMsg:
+			result = &msg
+			continue
+		case events.SendMsg:
+			sent = append(sent, msg.Data)
+			msgs = append(msgs, events.SerialTxMsg{Data: msg.Data, Tag: msg.Tag})
+			msgs = append(msgs, dev(msg.Data)...)
+		}
+
+		var cmd tea.Cmd
+		m, cmd = m.Update(msg)
+		run(cmd)
+	}
+
+	return strings.Join(sent, "|"), result.Err
+}
+
+func TestScript(t *testing.T) {
+	dev := func(data string) []tea.Msg {
+		switch data {
+		case "":
+			return []tea.Msg{events.SerialRxPartialMsg("log"), events.SerialRxPartialMsg("login: ")}
+		case "root":
+			return []tea.Msg{events.SerialRxMsgReceived("login: root"), events.SerialRxMsgReceived("ip 10.0.0.7"), events.SerialRxPartialMsg("# ")}
+		}
+		// echo behind the prompt
+		return []tea.Msg{events.SerialRxMsgReceived("# " + data), events.SerialRxPartialMsg("# ")}
+	}
+
+	sent, err := runScript(t, `
+# login and use the ip address
+send
+expect "login: " 100ms
+send root
+expect "ip (\d+\.\d+\.\d+\.\d+)"
+set ip ${1}
+expect "# $"
+loop 2
+  loop 2
+    send ping ${ip}
+    expect ping
+  end
+  sleep 1ms
+end
+sendraw \x03`, dev)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := `|root|ping 10.0.0.7|ping 10.0.0.7|ping 10.0.0.7|ping 10.0.0.7|\x03`; sent != want {
+		t.Errorf("sent = %q, want %q", sent, want)
+	}
+}
+
+func TestScriptFail(t *testing.T) {
+	echo := func(data string) []tea.Msg { return []tea.Msg{events.SerialRxMsgReceived(data)} }
+
+	tests := []struct {
+		script string
+		err    string
+	}{
+		{"send a\nexpect b 10ms", "test:2: expect \"b\" timed out"},
+		{"timeout 10ms\nsend a\nexpect a\nexpect a", "test:4: expect \"a\" timed out"},
+		{"send a\nexpect .\nfail got ${0}", "test:3: got a"},
+	}
+	for _, tt := range tests {
+		_, err := runScript(t, tt.script, echo)
+		if err == nil || err.Error() != tt.err {
+			t.Errorf("script %q: err = %v, want %q", tt.script, err, tt.err)
+		}
+	}
+}
+
+func TestParseInvalid(t *testing.T) {
+	for _, script := range []string{
+		"foo",
+		"expect",
+		`expect "a`,
+		"expect ( 1s",
+		"expect a 1",
+		"sleep x",
+		"sendhex 0g",
+		"set",
+		"loop x\nend",
+		"loop 2",
+		"end",
+	} {
+		if _, err := Parse("test", strings.Split(script, "\n")); err == nil {
+			t.Errorf("Parse(%q) succeeded, want error", script)
+		}
+	}
+}
+
+// Messages sent by the user and errors of the port while the script sends
+// are not the result of the script's send.
+func TestScriptOwnSend(t *testing.T) {
+	s, err := Parse("test", []string{"send a", "send b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(s)
+	m.Start()
+
+	m, _ = m.Update(events.SerialTxMsg{Data: "typed"})
+	m, _ = m.Update(events.ErrMsg(errors.New("port closed")))
+	m, _ = m.Update(events.ErrMsg(&session.SendError{Err: errors.New("typed not written")}))
+	if m.state != sending || m.pc != 1 {
+		t.Fatalf("state %d at %d, want still sending the first line", m.state, m.pc)
+	}
+
+	m, _ = m.Update(events.SerialTxMsg{Data: "a", Tag: sendTag})
+	if m.state != sending || m.pc != 2 {
+		t.Fatalf("state %d at %d, want sending the second line", m.state, m.pc)
+	}
+	_, cmd := m.Update(events.ErrMsg(&session.SendError{Err: errors.New("not written"), Tag: sendTag}))
+	if cmd == nil {
+		t.Error("failed send did not end the script")
+	}
+}
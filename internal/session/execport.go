@@ -0,0 +1,94 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+)
+
+// errProcessExited is the reason of the connection loss of an exec port,
+// if the command exited successfully.
+var errProcessExited = errors.New("process exited")
+
+// execPort runs a command and uses its stdio as port, e.g. the console of an
+// emulator like QEMU or Renode. Stdout and stderr of the command are received
+// data, sent data is written to its stdin.
+type execPort struct {
+	cmd   *exec.Cmd
+	addr  string // the port argument, e.g. exec:qemu-system-arm ...
+	stdin io.WriteCloser
+	out   *os.File
+
+	waitOnce sync.Once
+	waitErr  error
+}
+
+// openExecPort starts the command given as exec:command or
+// exec:"command with args". The command line is run by sh, so quoting and
+// variables work like in a shell.
+func openExecPort(name string) (Port, error) {
+	command := strings.TrimSpace(strings.TrimPrefix(name, "exec:"))
+	if len(command) >= 2 && command[0] == '"' && command[len(command)-1] == '"' {
+		command = command[1 : len(command)-1]
+	}
+	if command == "" {
+		return nil, errors.New("missing command, use exec:\"command args\"")
+	}
+
+	// exec replaces the shell, so closing the port stops the command itself
+	cmd := exec.Command("sh", "-c", "exec "+command)
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	out, w, err := os.Pipe()
+	if err != nil {
+		return nil, err
+	}
+	cmd.Stdout = w
+	cmd.Stderr = w
+
+	err = cmd.Start()
+	// the command has its own copy, the pipe ends when the command exits
+	w.Close()
+	if err != nil {
+		out.Close()
+		return nil, err
+	}
+
+	return &execPort{cmd: cmd, addr: name, stdin: stdin, out: out}, nil
+}
+
+func (p *execPort) Read(buf []byte) (int, error) {
+	n, err := p.out.Read(buf)
+	if err == io.EOF {
+		err = p.wait()
+		if err == nil {
+			err = errProcessExited
+		}
+	}
+	if err != nil {
+		return n, &ConnectionLostError{Addr: p.addr, Err: err}
+	}
+	return n, nil
+}
+
+func (p *execPort) Write(data []byte) (int, error) {
+	return p.stdin.Write(data)
+}
+
+// Close stops the command.
+func (p *execPort) Close() error {
+	p.stdin.Close()
+	p.cmd.Process.Kill()
+	p.wait()
+	return p.out.Close()
+}
+
+func (p *execPort) wait() error {
+	p.waitOnce.Do(func() { p.waitErr = p.cmd.Wait() })
+	return p.waitErr
+}
@@ -0,0 +1,72 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+func TestExecPort(t *testing.T) {
+	port, err := openExecPort(`exec:"cat"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	port.Write([]byte("hello\n"))
+	buf := make([]byte, 16)
+	n, err := io.ReadAtLeast(port, buf, 6)
+	if err != nil || string(buf[:n]) != "hello\n" {
+		t.Errorf("read %q, %v, want echo of cat", buf[:n], err)
+	}
+}
+
+func TestExecPortExit(t *testing.T) {
+	port, err := openExecPort(`exec:sh -c 'echo bye >&2; exit 3'`)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	data, err := io.ReadAll(port)
+	if string(data) != "bye\n" {
+		t.Errorf("read %q, want stderr of the command", data)
+	}
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) || lost.Err.Error() != "exit status 3" {
+		t.Errorf("err = %v, want connection lost with exit status 3", err)
+	}
+}
+
+func TestUnixPort(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "console.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conn.Write([]byte("boot\n"))
+			conn.Close()
+		}
+	}()
+
+	port, err := openUnixPort("unix:" + path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+
+	data, err := io.ReadAll(port)
+	if string(data) != "boot\n" {
+		t.Errorf("read %q, want boot", data)
+	}
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) {
+		t.Errorf("err = %v, want connection lost", err)
+	}
+}
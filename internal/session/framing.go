@@ -0,0 +1,207 @@
+package session
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mahlburgc/teaterm/internal/escape"
+)
+
+const (
+	delimFraming   = iota // frame ends with a delimiter sequence
+	anyFraming            // frame ends with any byte of a delimiter set
+	fixedFraming          // frames have a fixed length
+	timeoutFraming        // frame ends if no byte is received for a given time
+)
+
+// Framing defines how received data is split into messages.
+type Framing struct {
+	kind    int
+	delim   string // delimiter sequence or set of delimiter bytes
+	dropCR  bool   // drop a CR in front of the delimiter
+	length  int
+	timeout time.Duration
+	spec    string
+}
+
+// DefaultFraming splits received data into lines, like bufio.ScanLines.
+var DefaultFraming = Framing{kind: delimFraming, delim: "\n", dropCR: true, spec: "lf"}
+
+// ParseFraming converts a framing specification into a Framing.
+// Supported are:
+//
+//	lf              split on LF, a CR in front of the LF is dropped
+//	cr              split on CR
+//	crlf            split on CRLF only
+//	any             split on CR or LF, CRLF counts as one line ending
+//	any:<bytes>     split on any of the given (escaped) bytes
+//	delim:<bytes>   split on the given (escaped) byte sequence, e.g. delim:\x03
+//	fixed:<n>       split into frames of n bytes
+//	timeout:<dur>   split if no byte is received for the given duration, e.g. timeout:50ms
+func ParseFraming(spec string) (Framing, error) {
+	switch strings.ToLower(spec) {
+	case "lf":
+		return DefaultFraming, nil
+	case "cr":
+		return Framing{kind: delimFraming, delim: "\r", spec: "cr"}, nil
+	case "crlf":
+		return Framing{kind: delimFraming, delim: "\r\n", spec: "crlf"}, nil
+	case "any":
+		return Framing{kind: anyFraming, delim: "\r\n", spec: "any"}, nil
+	}
+
+	name, arg, found := strings.Cut(spec, ":")
+	name = strings.ToLower(name)
+	if !found || arg == "" {
+		return Framing{}, fmt.Errorf("invalid framing %q", spec)
+	}
+	f := Framing{spec: name + ":" + arg}
+
+	switch name {
+	case "any", "delim":
+		delim, err := escape.Unescape(arg)
+		if err != nil {
+			return Framing{}, fmt.Errorf("invalid framing %q: %w", spec, err)
+		}
+		f.kind = delimFraming
+		if name == "any" {
+			f.kind = anyFraming
+		}
+		f.delim = delim
+
+	case "fixed":
+		length, err := strconv.Atoi(arg)
+		if err != nil || length <= 0 {
+			return Framing{}, fmt.Errorf("invalid framing %q: length must be a positive number", spec)
+		}
+		f.kind = fixedFraming
+		f.length = length
+
+	case "timeout":
+		timeout, err := time.ParseDuration(arg)
+		if err != nil || timeout <= 0 {
+			return Framing{}, fmt.Errorf("invalid framing %q: timeout must be a positive duration like 50ms", spec)
+		}
+		f.kind = timeoutFraming
+		f.timeout = timeout
+
+	default:
+		return Framing{}, fmt.Errorf("invalid framing %q", spec)
+	}
+
+	return f, nil
+}
+
+// String returns the framing specification, see ParseFraming.
+func (f Framing) String() string {
+	return f.spec
+}
+
+// splitter splits buffered data into frames. It keeps the state that has to
+// survive between two reads, e.g. a CR at the end of the last read.
+type splitter struct {
+	buf    []byte
+	skipLF bool // last frame ended with CR, skip a following LF (any framing)
+}
+
+// next removes the next complete frame from the buffer.
+// Timeout framed data is never complete, it is flushed by the reader.
+func (s *splitter) next(f Framing) ([]byte, bool) {
+	if f.kind == anyFraming && s.skipLF && len(s.buf) > 0 {
+		if s.buf[0] == '\n' {
+			s.buf = s.buf[1:]
+		}
+		s.skipLF = false
+	}
+
+	switch f.kind {
+	case delimFraming:
+		i := bytes.Index(s.buf, []byte(f.delim))
+		if i < 0 {
+			return nil, false
+		}
+		frame := s.buf[:i]
+		s.buf = s.buf[i+len(f.delim):]
+		if f.dropCR {
+			frame = bytes.TrimSuffix(frame, []byte("\r"))
+		}
+		return frame, true
+
+	case anyFraming:
+		for i, b := range s.buf {
+			if strings.IndexByte(f.delim, b) >= 0 {
+				frame := s.buf[:i]
+				s.buf = s.buf[i+1:]
+				s.skipLF = b == '\r'
+				return frame, true
+			}
+		}
+
+	case fixedFraming:
+		if len(s.buf) >= f.length {
+			frame := s.buf[:f.length]
+			s.buf = s.buf[f.length:]
+			return frame, true
+		}
+	}
+
+	return nil, false
+}
+
+// split removes the first maxLen bytes from the buffer, if the next frame is
+// longer than maxLen. The cut is moved in front of an incomplete UTF-8
+// sequence. Fixed length frames are never split, a maxLen of 0 disables it.
+func (s *splitter) split(f Framing, maxLen int) ([]byte, bool) {
+	if maxLen <= 0 || f.kind == fixedFraming || len(s.buf) <= maxLen {
+		return nil, false
+	}
+
+	switch f.kind {
+	case delimFraming:
+		end := bytes.Index(s.buf, []byte(f.delim))
+		if end < 0 {
+			// a delimiter crossing the cut has to be buffered completely
+			need := maxLen + len(f.delim)
+			if f.dropCR {
+				need++
+			}
+			if len(s.buf) < need {
+				return nil, false
+			}
+		}
+		if end >= 0 && end <= maxLen {
+			return nil, false
+		}
+		if end == maxLen+1 && f.dropCR && s.buf[maxLen] == '\r' {
+			return nil, false
+		}
+
+	case anyFraming:
+		if end := bytes.IndexAny(s.buf, f.delim); end >= 0 && end <= maxLen {
+			return nil, false
+		}
+	}
+
+	cut := maxLen
+	for i := cut; i > 0 && i > cut-utf8.UTFMax; i-- {
+		if utf8.RuneStart(s.buf[i]) {
+			cut = i
+			break
+		}
+	}
+
+	frame := s.buf[:cut]
+	s.buf = s.buf[cut:]
+	return frame, true
+}
+
+// flush removes all buffered data.
+func (s *splitter) flush() []byte {
+	frame := s.buf
+	s.buf = nil
+	return frame
+}
@@ -0,0 +1,115 @@
+package session
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/handover"
+)
+
+// source of the data forwarded from the pty mirror, shown in the message log
+const ptySource = "pty"
+
+type (
+	// Data written to the pty mirror by another program.
+	mirrorRxMsg []byte
+	// Sent when the data of the pty mirror was written to the port.
+	mirrorTxMsg struct {
+		data []byte
+		err  error
+	}
+)
+
+// mirror holds the pty mirror of the session. It is shared by all copies of
+// the model and the port readers.
+type mirror struct {
+	pty atomic.Pointer[Pty]
+	// data of the pty goes to the program restarted after the editor
+	reads handover.Latest[tea.Msg]
+	// the path is shown once, not on every restart
+	announce sync.Once
+}
+
+// mirrorReader passes everything read from the port to the pty mirror.
+type mirrorReader struct {
+	port   io.Reader
+	mirror *mirror
+}
+
+func (r mirrorReader) Read(buf []byte) (int, error) {
+	n, err := r.port.Read(buf)
+	if pty := r.mirror.pty.Load(); pty != nil && n > 0 {
+		pty.Write(buf[:n])
+	}
+	return n, err
+}
+
+// SetMirror mirrors the session to the pty. Must be called before Init.
+func (m *Model) SetMirror(pty *Pty) {
+	m.mirror.pty.Store(pty)
+}
+
+// Returns a tea command that reads the next data written to the pty mirror.
+func (m Model) readMirror() tea.Cmd {
+	pty := m.mirror.pty.Load()
+	if pty == nil {
+		return nil
+	}
+	mirror := m.mirror
+	return func() tea.Msg {
+		msg, _ := mirror.reads.Wait(func() tea.Msg {
+			buf := make([]byte, 4096)
+			n, err := pty.Read(buf)
+			if err != nil {
+				return events.ErrMsg(fmt.Errorf("pty mirror: %w", err))
+			}
+			return mirrorRxMsg(buf[:n])
+		})
+		return msg
+	}
+}
+
+// Forward data of the pty mirror to the port. Data written while the port
+// is not connected is dropped.
+func (m Model) forwardMirrorData(data []byte) tea.Cmd {
+	if m.status != connected {
+		return m.readMirror()
+	}
+	port := *m.port
+	return func() tea.Msg {
+		_, err := port.Write(data)
+		return mirrorTxMsg{data: data, err: err}
+	}
+}
+
+// Report forwarded data and continue reading the pty mirror.
+func (m Model) mirrorDataForwarded(msg mirrorTxMsg) tea.Cmd {
+	reportCmd := func() tea.Msg {
+		if msg.err != nil {
+			// wrapped, a failed write must not start a reconnect
+			return events.ErrMsg(fmt.Errorf("pty mirror: %w", msg.err))
+		}
+		return events.ForwardedTxMsg{Data: string(msg.data), Source: ptySource}
+	}
+	return tea.Batch(reportCmd, m.readMirror())
+}
+
+// Returns a tea command showing the path of the pty mirror, on the first
+// call only.
+func (m Model) mirrorInfo() tea.Cmd {
+	pty := m.mirror.pty.Load()
+	if pty == nil {
+		return nil
+	}
+	var cmd tea.Cmd
+	m.mirror.announce.Do(func() {
+		cmd = func() tea.Msg {
+			return events.InfoMsg(fmt.Sprintf("Session mirrored to %s", pty.Path()))
+		}
+	})
+	return cmd
+}
@@ -0,0 +1,143 @@
+package session
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mahlburgc/teaterm/internal/escape"
+	"go.bug.st/serial"
+)
+
+// Settings holds everything needed to open and talk to a port.
+type Settings struct {
+	Port       string
+	Mode       serial.Mode
+	LineEnding string // appended to every sent message
+	Framing    Framing
+	IdleFlush  time.Duration // show unterminated received data after this idle time, 0 disables
+
+	// Received lines longer than this are split into several messages, 0 disables.
+	MaxLineLength int
+}
+
+var lineEndingNames = map[string]string{
+	"\r\n": "CRLF",
+	"\r":   "CR",
+	"\n":   "LF",
+	"":     "none",
+}
+
+var parityNames = map[serial.Parity]string{
+	serial.NoParity:    "N",
+	serial.OddParity:   "O",
+	serial.EvenParity:  "E",
+	serial.MarkParity:  "M",
+	serial.SpaceParity: "S",
+}
+
+var stopBitsNames = map[serial.StopBits]string{
+	serial.OneStopBit:           "1",
+	serial.OnePointFiveStopBits: "1.5",
+	serial.TwoStopBits:          "2",
+}
+
+// NewMode validates the given line settings and returns the resulting serial mode.
+// Parity can be given as full name (none, odd, even, mark, space) or as the
+// first letter, stop bits as 1, 1.5 or 2.
+func NewMode(baudRate int, dataBits int, parity string, stopBits string, dtr bool, rts bool) (serial.Mode, error) {
+	mode := serial.Mode{
+		BaudRate:          baudRate,
+		DataBits:          dataBits,
+		InitialStatusBits: &serial.ModemOutputBits{DTR: dtr, RTS: rts},
+	}
+
+	if baudRate <= 0 {
+		return mode, fmt.Errorf("invalid baud rate %d", baudRate)
+	}
+
+	if dataBits < 5 || dataBits > 8 {
+		return mode, fmt.Errorf("invalid data bits %d, must be 5, 6, 7 or 8", dataBits)
+	}
+
+	p, err := ParseParity(parity)
+	if err != nil {
+		return mode, err
+	}
+	mode.Parity = p
+
+	s, err := ParseStopBits(stopBits)
+	if err != nil {
+		return mode, err
+	}
+	mode.StopBits = s
+
+	return mode, nil
+}
+
+// ParseParity converts a parity name into a serial parity.
+func ParseParity(parity string) (serial.Parity, error) {
+	switch strings.ToLower(parity) {
+	case "n", "none":
+		return serial.NoParity, nil
+	case "o", "odd":
+		return serial.OddParity, nil
+	case "e", "even":
+		return serial.EvenParity, nil
+	case "m", "mark":
+		return serial.MarkParity, nil
+	case "s", "space":
+		return serial.SpaceParity, nil
+	}
+	return serial.NoParity, fmt.Errorf("invalid parity %q, must be none, odd, even, mark or space", parity)
+}
+
+// ParseStopBits converts a stop bits string into serial stop bits.
+func ParseStopBits(stopBits string) (serial.StopBits, error) {
+	for s, name := range stopBitsNames {
+		if name == stopBits {
+			return s, nil
+		}
+	}
+	return serial.OneStopBit, fmt.Errorf("invalid stop bits %q, must be 1, 1.5 or 2", stopBits)
+}
+
+// sameMode reports whether two serial modes have the same line settings.
+func sameMode(a serial.Mode, b serial.Mode) bool {
+	return a.BaudRate == b.BaudRate && a.DataBits == b.DataBits &&
+		a.Parity == b.Parity && a.StopBits == b.StopBits
+}
+
+// ParseLineEnding converts a line ending name (cr, lf, crlf or none) or an
+// escaped byte sequence like \x00 into the line ending bytes.
+func ParseLineEnding(lineEnding string) (string, error) {
+	for bytes, name := range lineEndingNames {
+		if strings.EqualFold(name, lineEnding) {
+			return bytes, nil
+		}
+	}
+
+	bytes, err := escape.Unescape(lineEnding)
+	if err != nil {
+		return "", fmt.Errorf("invalid line ending %q: %w", lineEnding, err)
+	}
+	return bytes, nil
+}
+
+// FormatLineEnding returns the display name of a line ending, e.g. "CRLF".
+// Custom line endings are shown escaped, e.g. "\x00".
+func FormatLineEnding(lineEnding string) string {
+	if name, ok := lineEndingNames[lineEnding]; ok {
+		return name
+	}
+	return escape.Quote(lineEnding)
+}
+
+// FormatMode returns the short notation of a serial mode, e.g. "115200 8N1".
+func FormatMode(mode serial.Mode) string {
+	dataBits := mode.DataBits
+	if dataBits == 0 {
+		dataBits = 8 // serial package default
+	}
+	return fmt.Sprintf("%d %d%s%s", mode.BaudRate, dataBits, parityNames[mode.Parity], stopBitsNames[mode.StopBits])
+}
@@ -0,0 +1,40 @@
+package session
+
+import "testing"
+
+func TestNewMode(t *testing.T) {
+	tests := []struct {
+		baudRate int
+		dataBits int
+		parity   string
+		stopBits string
+		want     string // FormatMode output, "" if invalid
+	}{
+		{115200, 8, "none", "1", "115200 8N1"},
+		{9600, 7, "E", "1", "9600 7E1"},
+		{921600, 8, "n", "2", "921600 8N2"},
+		{300, 5, "mark", "1.5", "300 5M1.5"},
+		{0, 8, "none", "1", ""},
+		{9600, 9, "none", "1", ""},
+		{9600, 8, "x", "1", ""},
+		{9600, 8, "none", "3", ""},
+	}
+
+	for _, tt := range tests {
+		mode, err := NewMode(tt.baudRate, tt.dataBits, tt.parity, tt.stopBits, true, true)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("NewMode(%d, %d, %q, %q) = %q, want error",
+					tt.baudRate, tt.dataBits, tt.parity, tt.stopBits, FormatMode(mode))
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("NewMode(%d, %d, %q, %q) failed: %v", tt.baudRate, tt.dataBits, tt.parity, tt.stopBits, err)
+			continue
+		}
+		if got := FormatMode(mode); got != tt.want {
+			t.Errorf("FormatMode = %q, want %q", got, tt.want)
+		}
+	}
+}
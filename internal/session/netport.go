@@ -0,0 +1,367 @@
+package session
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// timeout to establish the connection of a network port
+const dialTimeout = 5 * time.Second
+
+// Telnet commands and options, see RFC 854, RFC 856, RFC 858 and RFC 2217.
+const (
+	telnetSE   = 240
+	telnetSB   = 250
+	telnetWILL = 251
+	telnetWONT = 252
+	telnetDO   = 253
+	telnetDONT = 254
+	telnetIAC  = 255
+
+	optBinary  = 0
+	optSGA     = 3 // suppress go ahead
+	optComPort = 44
+)
+
+// RFC 2217 com port control commands sent by the client.
+const (
+	comSetBaudRate = 1
+	comSetDataSize = 2
+	comSetParity   = 3
+	comSetStopSize = 4
+	comSetControl  = 5
+)
+
+// RFC 2217 values of the set control command.
+const (
+	comDTROn  = 8
+	comDTROff = 9
+	comRTSOn  = 11
+	comRTSOff = 12
+)
+
+var comParity = map[serial.Parity]byte{
+	serial.NoParity:    1,
+	serial.OddParity:   2,
+	serial.EvenParity:  3,
+	serial.MarkParity:  4,
+	serial.SpaceParity: 5,
+}
+
+var comStopSize = map[serial.StopBits]byte{
+	serial.OneStopBit:           1,
+	serial.TwoStopBits:          2,
+	serial.OnePointFiveStopBits: 3,
+}
+
+// ConnectionLostError is returned by network ports when the connection is
+// closed by the remote side or fails and by exec ports when the command exits.
+// The session reconnects like it does for a removed serial port.
+type ConnectionLostError struct {
+	Addr string
+	Err  error
+}
+
+func (e *ConnectionLostError) Error() string {
+	return fmt.Sprintf("connection to %s lost: %v", e.Addr, e.Err)
+}
+
+func (e *ConnectionLostError) Unwrap() error {
+	return e.Err
+}
+
+// telnet decoder states
+const (
+	telnetData = iota
+	telnetCmd  // after IAC
+	telnetOpt  // after IAC and a negotiation command
+	telnetSub  // subnegotiation data
+	telnetSubIAC
+)
+
+// netPort is a port reached over TCP: a raw socket, a telnet connection or a
+// RFC 2217 remote serial port like ser2net or a terminal server. Unix domain
+// sockets of emulators like QEMU are raw sockets as well.
+type netPort struct {
+	conn    net.Conn
+	addr    string // the port argument, e.g. telnet://host:2001
+	telnet  bool
+	comPort bool // RFC 2217
+
+	// serializes the writes of data and telnet negotiation
+	writeMu sync.Mutex
+
+	// telnet decoder, only used by Read
+	state int
+	cmd   byte
+	sub   []byte
+
+	// negotiated options, enabled on our side and on the remote side
+	local  map[byte]bool
+	remote map[byte]bool
+
+	modeMu sync.Mutex
+	mode   serial.Mode
+	// the server accepted the com port option, the mode can be sent
+	comPortActive bool
+}
+
+// isNetPort reports whether the port argument is a network address.
+func isNetPort(name string) bool {
+	for _, scheme := range []string{"tcp://", "telnet://", "rfc2217://"} {
+		if strings.HasPrefix(name, scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// openNetPort connects to a network port given as tcp://host:port,
+// telnet://host:port or rfc2217://host:port. The serial mode is only used by
+// RFC 2217 ports.
+func openNetPort(name string, mode *serial.Mode) (Port, error) {
+	scheme, addr, _ := strings.Cut(name, "://")
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return nil, fmt.Errorf("invalid address %q, use %s://host:port", addr, scheme)
+	}
+
+	conn, err := net.DialTimeout("tcp", addr, dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &netPort{
+		conn:    conn,
+		addr:    name,
+		telnet:  scheme != "tcp",
+		comPort: scheme == "rfc2217",
+		local:   make(map[byte]bool),
+		remote:  make(map[byte]bool),
+		mode:    *mode,
+	}
+	if !p.telnet {
+		return p, nil
+	}
+
+	// 8 bit clean connection without go ahead in both directions
+	p.local[optBinary], p.remote[optBinary] = true, true
+	p.local[optSGA], p.remote[optSGA] = true, true
+	negotiation := []byte{
+		telnetIAC, telnetWILL, optBinary, telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA, telnetIAC, telnetDO, optSGA,
+	}
+	if p.comPort {
+		negotiation = append(negotiation, telnetIAC, telnetWILL, optComPort)
+	}
+	if err := p.writeRaw(negotiation); err != nil {
+		conn.Close()
+		return nil, err
+	}
+	return p, nil
+}
+
+// openUnixPort connects to a Unix domain socket given as unix:/path.
+func openUnixPort(name string) (Port, error) {
+	conn, err := net.DialTimeout("unix", strings.TrimPrefix(name, "unix:"), dialTimeout)
+	if err != nil {
+		return nil, err
+	}
+	return &netPort{conn: conn, addr: name}, nil
+}
+
+func (p *netPort) Read(buf []byte) (int, error) {
+	raw := make([]byte, len(buf))
+	for {
+		n, err := p.conn.Read(raw)
+		if err != nil {
+			return 0, &ConnectionLostError{Addr: p.addr, Err: err}
+		}
+		if !p.telnet {
+			return copy(buf, raw[:n]), nil
+		}
+		// a read of only telnet commands returns no data, read again
+		if n = p.decode(raw[:n], buf); n > 0 {
+			return n, nil
+		}
+	}
+}
+
+func (p *netPort) Write(data []byte) (int, error) {
+	out := data
+	if p.telnet {
+		out = bytes.ReplaceAll(data, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})
+	}
+	if err := p.writeRaw(out); err != nil {
+		return 0, err
+	}
+	return len(data), nil
+}
+
+func (p *netPort) writeRaw(data []byte) error {
+	p.writeMu.Lock()
+	defer p.writeMu.Unlock()
+	_, err := p.conn.Write(data)
+	return err
+}
+
+func (p *netPort) Close() error {
+	return p.conn.Close()
+}
+
+// SetMode sends the serial mode to a RFC 2217 server. If the server did not
+// accept the com port option yet, the mode is sent once it does. Other
+// network ports have no line settings, the mode is only stored.
+func (p *netPort) SetMode(mode *serial.Mode) error {
+	p.modeMu.Lock()
+	p.mode = *mode
+	active := p.comPortActive
+	p.modeMu.Unlock()
+
+	if !p.comPort || !active {
+		return nil
+	}
+	return p.sendMode()
+}
+
+// sendMode sends baud rate, line control and the modem control lines.
+func (p *netPort) sendMode() error {
+	p.modeMu.Lock()
+	mode := p.mode
+	p.modeMu.Unlock()
+
+	var out []byte
+	sub := func(cmd byte, value ...byte) {
+		out = append(out, telnetIAC, telnetSB, optComPort, cmd)
+		out = append(out, bytes.ReplaceAll(value, []byte{telnetIAC}, []byte{telnetIAC, telnetIAC})...)
+		out = append(out, telnetIAC, telnetSE)
+	}
+
+	sub(comSetBaudRate, binary.BigEndian.AppendUint32(nil, uint32(mode.BaudRate))...)
+	sub(comSetDataSize, byte(mode.DataBits))
+	sub(comSetParity, comParity[mode.Parity])
+	sub(comSetStopSize, comStopSize[mode.StopBits])
+	if bits := mode.InitialStatusBits; bits != nil {
+		sub(comSetControl, map[bool]byte{true: comDTROn, false: comDTROff}[bits.DTR])
+		sub(comSetControl, map[bool]byte{true: comRTSOn, false: comRTSOff}[bits.RTS])
+	}
+	return p.writeRaw(out)
+}
+
+// decode removes the telnet commands from the received data, answers the
+// option negotiation and returns the number of data bytes written to out.
+func (p *netPort) decode(in []byte, out []byte) int {
+	n := 0
+	for _, b := range in {
+		switch p.state {
+		case telnetData:
+			if b == telnetIAC {
+				p.state = telnetCmd
+				continue
+			}
+			out[n] = b
+			n++
+
+		case telnetCmd:
+			switch b {
+			case telnetIAC:
+				out[n] = b
+				n++
+				p.state = telnetData
+			case telnetWILL, telnetWONT, telnetDO, telnetDONT:
+				p.cmd = b
+				p.state = telnetOpt
+			case telnetSB:
+				p.sub = p.sub[:0]
+				p.state = telnetSub
+			default:
+				// NOP, go ahead, ...
+				p.state = telnetData
+			}
+
+		case telnetOpt:
+			p.negotiate(p.cmd, b)
+			p.state = telnetData
+
+		case telnetSub:
+			if b == telnetIAC {
+				p.state = telnetSubIAC
+			} else if len(p.sub) < 256 {
+				p.sub = append(p.sub, b)
+			}
+
+		case telnetSubIAC:
+			switch b {
+			case telnetSE:
+				// answers and notifications of the com port option are
+				// not used
+				p.state = telnetData
+			case telnetIAC:
+				p.sub = append(p.sub, b)
+				p.state = telnetSub
+			default:
+				p.state = telnetSub
+			}
+		}
+	}
+	return n
+}
+
+// negotiate answers an option request of the server. Only requests that
+// change the state of an option are answered, so the negotiation does not
+// loop.
+func (p *netPort) negotiate(cmd byte, opt byte) {
+	supported := opt == optBinary || opt == optSGA || (opt == optComPort && p.comPort)
+
+	var answer byte
+	switch cmd {
+	case telnetDO:
+		if opt == optComPort && supported {
+			p.modeMu.Lock()
+			p.comPortActive = true
+			p.modeMu.Unlock()
+			p.local[opt] = true
+			p.sendMode()
+			return
+		}
+		switch {
+		case !supported:
+			answer = telnetWONT
+		case !p.local[opt]:
+			p.local[opt] = true
+			answer = telnetWILL
+		}
+
+	case telnetDONT:
+		if p.local[opt] {
+			p.local[opt] = false
+			answer = telnetWONT
+		}
+
+	case telnetWILL:
+		switch {
+		case !supported || opt == optComPort:
+			// the com port option is only offered by the client
+			answer = telnetDONT
+		case !p.remote[opt]:
+			p.remote[opt] = true
+			answer = telnetDO
+		}
+
+	case telnetWONT:
+		if p.remote[opt] {
+			p.remote[opt] = false
+			answer = telnetDONT
+		}
+	}
+
+	if answer != 0 {
+		p.writeRaw([]byte{telnetIAC, answer, opt})
+	}
+}
@@ -0,0 +1,182 @@
+package session
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"go.bug.st/serial"
+)
+
+// listen starts a server for one connection and returns its address and
+// the accepted connection.
+func listen(t *testing.T) (string, <-chan net.Conn) {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	conns := make(chan net.Conn, 1)
+	go func() {
+		conn, err := l.Accept()
+		if err == nil {
+			conns <- conn
+		}
+	}()
+	return l.Addr().String(), conns
+}
+
+// readN reads n bytes from the connection.
+func readN(t *testing.T, conn net.Conn, n int) []byte {
+	t.Helper()
+
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("read %d bytes: %v", n, err)
+	}
+	return buf
+}
+
+func TestTCPPort(t *testing.T) {
+	addr, conns := listen(t)
+	mode := serial.Mode{BaudRate: 115200}
+	port, err := openNetPort("tcp://"+addr, &mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	server := <-conns
+
+	// raw sockets pass all bytes unchanged
+	port.Write([]byte{'a', telnetIAC})
+	if got := readN(t, server, 2); !bytes.Equal(got, []byte{'a', telnetIAC}) {
+		t.Errorf("server received %q", got)
+	}
+
+	// a raw socket has no line settings, a new mode is accepted but not sent
+	mode.BaudRate = 9600
+	if err := port.(modeSetter).SetMode(&mode); err != nil {
+		t.Errorf("SetMode: %v", err)
+	}
+	port.Write([]byte{'b'})
+	if got := readN(t, server, 1); !bytes.Equal(got, []byte{'b'}) {
+		t.Errorf("server received %q after SetMode", got)
+	}
+
+	server.Write([]byte{telnetIAC, telnetDO, optBinary})
+	buf := make([]byte, 16)
+	n, _ := port.Read(buf)
+	if !bytes.Equal(buf[:n], []byte{telnetIAC, telnetDO, optBinary}) {
+		t.Errorf("port received %q", buf[:n])
+	}
+
+	server.Close()
+	_, err = port.Read(buf)
+	var lost *ConnectionLostError
+	if !errors.As(err, &lost) {
+		t.Errorf("read after close: err = %v, want connection lost", err)
+	}
+}
+
+func TestTelnetPort(t *testing.T) {
+	addr, conns := listen(t)
+	mode := serial.Mode{BaudRate: 115200}
+	port, err := openNetPort("telnet://"+addr, &mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	server := <-conns
+
+	want := []byte{
+		telnetIAC, telnetWILL, optBinary, telnetIAC, telnetDO, optBinary,
+		telnetIAC, telnetWILL, optSGA, telnetIAC, telnetDO, optSGA,
+	}
+	if got := readN(t, server, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("negotiation = %v, want %v", got, want)
+	}
+
+	// the accepted options are not answered again, echo is refused
+	server.Write([]byte{telnetIAC, telnetDO, optBinary, telnetIAC, telnetWILL, 1})
+	server.Write([]byte("ok\r\n"))
+	server.Write([]byte{telnetIAC, telnetSB, 24, 1, telnetIAC, telnetSE, telnetIAC, telnetIAC, 'x'})
+
+	var got []byte
+	buf := make([]byte, 16)
+	for len(got) < 6 {
+		n, err := port.Read(buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, buf[:n]...)
+	}
+	if want := []byte{'o', 'k', '\r', '\n', telnetIAC, 'x'}; !bytes.Equal(got, want) {
+		t.Errorf("port received %v, want %v", got, want)
+	}
+	if answer := readN(t, server, 3); !bytes.Equal(answer, []byte{telnetIAC, telnetDONT, 1}) {
+		t.Errorf("answer = %v, want DONT ECHO", answer)
+	}
+
+	// IAC is escaped in sent data
+	port.Write([]byte{telnetIAC, 'a'})
+	if got := readN(t, server, 3); !bytes.Equal(got, []byte{telnetIAC, telnetIAC, 'a'}) {
+		t.Errorf("server received %v", got)
+	}
+}
+
+func TestRFC2217Port(t *testing.T) {
+	addr, conns := listen(t)
+	mode, _ := NewMode(9600, 7, "even", "2", true, false)
+	port, err := openNetPort("rfc2217://"+addr, &mode)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer port.Close()
+	server := <-conns
+
+	if got := readN(t, server, 15); !bytes.Equal(got[12:], []byte{telnetIAC, telnetWILL, optComPort}) {
+		t.Errorf("negotiation = %v, want WILL COM-PORT-OPTION", got)
+	}
+
+	// the mode is sent once the server accepts the option
+	server.Write([]byte{telnetIAC, telnetDO, optComPort})
+	go port.Read(make([]byte, 16))
+
+	sub := func(cmd byte, value ...byte) []byte {
+		return append(append([]byte{telnetIAC, telnetSB, optComPort, cmd}, value...), telnetIAC, telnetSE)
+	}
+	want := bytes.Join([][]byte{
+		sub(comSetBaudRate, 0, 0, 0x25, 0x80),
+		sub(comSetDataSize, 7),
+		sub(comSetParity, 3),
+		sub(comSetStopSize, 2),
+		sub(comSetControl, comDTROn),
+		sub(comSetControl, comRTSOff),
+	}, nil)
+	if got := readN(t, server, len(want)); !bytes.Equal(got, want) {
+		t.Errorf("mode = %v, want %v", got, want)
+	}
+
+	// baud rate changes are passed through
+	mode.BaudRate = 115200
+	if err := port.(modeSetter).SetMode(&mode); err != nil {
+		t.Fatal(err)
+	}
+	if got := readN(t, server, len(want)); !bytes.Equal(got[:10], sub(comSetBaudRate, 0, 1, 0xc2, 0)) {
+		t.Errorf("mode = %v, want baud rate 115200", got)
+	}
+}
+
+func TestInvalidNetPort(t *testing.T) {
+	mode := serial.Mode{}
+	if _, err := openNetPort("tcp://localhost", &mode); err == nil {
+		t.Error("address without port accepted")
+	}
+}
@@ -0,0 +1,137 @@
+package session
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// Pty is a pseudo-terminal mirroring the session for other programs. Data
+// received from the port is written to it, data written by other programs is
+// sent to the port.
+type Pty struct {
+	master *os.File
+	// kept open, so reading the master does not fail while no other
+	// program has the pty open
+	slave *os.File
+	// symlink to the pty device, e.g. /tmp/teaterm-ttyUSB0
+	link string
+}
+
+// OpenPty creates a pseudo-terminal in raw mode and a symlink
+// /tmp/teaterm-<port> to it. If the symlink exists, a number is appended.
+func OpenPty(port string) (*Pty, error) {
+	master, err := os.OpenFile("/dev/ptmx", os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		return nil, err
+	}
+	p := &Pty{master: master}
+
+	// The master stays in non-blocking mode, so the mirror does not block
+	// the session if nobody reads the pty. Fd would switch it to blocking.
+	conn, err := master.SyscallConn()
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	var n int
+	var ioctlErr error
+	conn.Control(func(fd uintptr) {
+		if ioctlErr = unix.IoctlSetPointerInt(int(fd), unix.TIOCSPTLCK, 0); ioctlErr != nil {
+			return
+		}
+		n, ioctlErr = unix.IoctlGetInt(int(fd), unix.TIOCGPTN)
+	})
+	if ioctlErr != nil {
+		p.Close()
+		return nil, fmt.Errorf("open pty: %w", ioctlErr)
+	}
+
+	p.slave, err = os.OpenFile(fmt.Sprintf("/dev/pts/%d", n), os.O_RDWR|syscall.O_NOCTTY, 0)
+	if err != nil {
+		p.Close()
+		return nil, err
+	}
+	if err := makeRaw(int(p.slave.Fd())); err != nil {
+		p.Close()
+		return nil, err
+	}
+
+	base := filepath.Join(os.TempDir(), "teaterm-"+filepath.Base(port))
+	for i := 1; i <= 10; i++ {
+		link := base
+		if i > 1 {
+			link = fmt.Sprintf("%s-%d", base, i)
+		}
+		err = os.Symlink(p.slave.Name(), link)
+		if err == nil {
+			p.link = link
+			return p, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			break
+		}
+	}
+	p.Close()
+	return nil, fmt.Errorf("create pty link: %w", err)
+}
+
+// makeRaw disables echo and all line processing like cfmakeraw.
+func makeRaw(fd int) error {
+	t, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return err
+	}
+	t.Iflag &^= unix.IGNBRK | unix.BRKINT | unix.PARMRK | unix.ISTRIP | unix.INLCR | unix.IGNCR | unix.ICRNL | unix.IXON
+	t.Oflag &^= unix.OPOST
+	t.Lflag &^= unix.ECHO | unix.ECHONL | unix.ICANON | unix.ISIG | unix.IEXTEN
+	t.Cflag &^= unix.CSIZE | unix.PARENB
+	t.Cflag |= unix.CS8
+	t.Cc[unix.VMIN] = 1
+	t.Cc[unix.VTIME] = 0
+	return unix.IoctlSetTermios(fd, unix.TCSETS, t)
+}
+
+// Path returns the symlink to the pty, that other programs open.
+func (p *Pty) Path() string {
+	return p.link
+}
+
+// Read returns the data written by other programs.
+func (p *Pty) Read(buf []byte) (int, error) {
+	return p.master.Read(buf)
+}
+
+// Write passes received data to the other programs. If nobody reads the pty
+// and its buffer is full, the data is dropped.
+func (p *Pty) Write(data []byte) (int, error) {
+	conn, err := p.master.SyscallConn()
+	if err != nil {
+		return 0, err
+	}
+	var n int
+	conn.Write(func(fd uintptr) bool {
+		n, err = unix.Write(int(fd), data)
+		// do not wait until the pty is writable
+		return true
+	})
+	if err == unix.EAGAIN {
+		return len(data), nil
+	}
+	return n, err
+}
+
+// Close removes the pty and its symlink.
+func (p *Pty) Close() error {
+	if p.link != "" {
+		os.Remove(p.link)
+	}
+	if p.slave != nil {
+		p.slave.Close()
+	}
+	return p.master.Close()
+}
@@ -0,0 +1,106 @@
+package session
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+func TestPty(t *testing.T) {
+	pty, err := OpenPty("/dev/ttyTEST")
+	if err != nil {
+		t.Skip("no pty support:", err)
+	}
+	defer pty.Close()
+
+	if !strings.HasSuffix(pty.Path(), "teaterm-ttyTEST") {
+		t.Errorf("path = %q", pty.Path())
+	}
+	other, err := os.OpenFile(pty.Path(), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	// received data is mirrored, in raw mode
+	m := &mirror{}
+	m.pty.Store(pty)
+	io.ReadAll(mirrorReader{strings.NewReader("rx\r\n"), m})
+	buf := make([]byte, 16)
+	n, _ := io.ReadAtLeast(other, buf, 4)
+	if got := buf[:n]; !bytes.Equal(got, []byte("rx\r\n")) {
+		t.Errorf("other side read %q", got)
+	}
+
+	other.Write([]byte("tx\r"))
+	n, _ = io.ReadAtLeast(pty, buf, 3)
+	if got := buf[:n]; !bytes.Equal(got, []byte("tx\r")) {
+		t.Errorf("pty read %q", got)
+	}
+
+	// nobody reads, the data is dropped instead of blocking
+	for range 1000 {
+		if _, err := pty.Write(make([]byte, 1024)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	pty.Close()
+	if _, err := os.Lstat(pty.Path()); err == nil {
+		t.Error("link not removed")
+	}
+}
+
+// After the editor restarts the program, data of the pty goes to the new
+// program and the path is not shown again.
+func TestPtyRestart(t *testing.T) {
+	pty, err := OpenPty("/dev/ttyTEST")
+	if err != nil {
+		t.Skip("no pty support:", err)
+	}
+	defer pty.Close()
+	other, err := os.OpenFile(pty.Path(), os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer other.Close()
+
+	r, w := io.Pipe()
+	defer w.Close()
+	port := io.ReadWriteCloser(struct {
+		io.Reader
+		io.WriteCloser
+	}{r, w})
+	m := New(&port, Settings{Port: "/dev/ttyTEST"})
+	m.SetMirror(pty)
+	if m.mirrorInfo() == nil {
+		t.Error("path of the mirror not shown")
+	}
+	if m.mirrorInfo() != nil {
+		t.Error("path of the mirror shown again")
+	}
+
+	old, restarted := make(chan tea.Msg, 1), make(chan tea.Msg, 1)
+	go func() { old <- m.readMirror()() }()
+	time.Sleep(20 * time.Millisecond)
+	go func() { restarted <- m.readMirror()() }()
+	time.Sleep(20 * time.Millisecond)
+	other.Write([]byte("tx\r"))
+
+	select {
+	case msg := <-restarted:
+		if got, ok := msg.(mirrorRxMsg); !ok || string(got) != "tx\r" {
+			t.Errorf("new program got %#v, want the data", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new program got no data")
+	}
+	if msg := <-old; msg != nil {
+		t.Errorf("old program got %#v", msg)
+	}
+}
@@ -0,0 +1,17 @@
+//go:build !linux
+
+package session
+
+import "errors"
+
+// Pty is a pseudo-terminal mirroring the session, only supported on Linux.
+type Pty struct{}
+
+func OpenPty(port string) (*Pty, error) {
+	return nil, errors.New("the pty mirror is only supported on linux")
+}
+
+func (p *Pty) Path() string                   { return "" }
+func (p *Pty) Read(buf []byte) (int, error)   { return 0, errors.ErrUnsupported }
+func (p *Pty) Write(data []byte) (int, error) { return len(data), nil }
+func (p *Pty) Close() error                   { return nil }
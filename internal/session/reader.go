@@ -0,0 +1,177 @@
+package session
+
+import (
+	"bytes"
+	"io"
+	"sync"
+	"time"
+
+	"github.com/mahlburgc/teaterm/internal/handover"
+)
+
+const (
+	completeFrame = iota
+	partialFrame  // unterminated data after the idle time, returned again once complete
+	splitFrame    // first part of a frame longer than the maximum line length
+)
+
+// rxChunk is a chunk of raw data or a read error from the port.
+type rxChunk struct {
+	data []byte
+	err  error
+}
+
+// rxFrame is a frame returned by next, with its kind or the read error.
+type rxFrame struct {
+	data []byte
+	kind int
+	err  error
+}
+
+// rxReader reads raw data from the port in a background goroutine and
+// splits it into frames according to the configured framing.
+// Frames are fetched with read or next, only one call to next may be pending.
+type rxReader struct {
+	chunks   chan rxChunk
+	done     chan struct{}
+	stopOnce sync.Once
+
+	reads handover.Latest[rxFrame] // the frame goes to the latest read
+
+	mu       sync.Mutex
+	settings Settings // framing, idle flush and maximum line length
+
+	splitter   splitter
+	partialLen int   // length of the unterminated data already returned as partial frame
+	err        error // read error, reported after all buffered data
+}
+
+func newRxReader(port io.Reader, settings Settings) *rxReader {
+	r := &rxReader{
+		chunks:   make(chan rxChunk),
+		done:     make(chan struct{}),
+		settings: settings,
+	}
+	go r.readLoop(port)
+	return r
+}
+
+// readLoop passes everything read from the port to the chunks channel
+// until the port returns an error or the reader is stopped.
+func (r *rxReader) readLoop(port io.Reader) {
+	for {
+		buf := make([]byte, 4096)
+		n, err := port.Read(buf)
+		if n > 0 && !r.send(rxChunk{data: buf[:n]}) {
+			return
+		}
+		if err != nil {
+			r.send(rxChunk{err: err})
+			return
+		}
+	}
+}
+
+func (r *rxReader) send(chunk rxChunk) bool {
+	select {
+	case r.chunks <- chunk:
+		return true
+	case <-r.done:
+		return false
+	}
+}
+
+// stop terminates the read loop. The port has to be closed by the caller.
+func (r *rxReader) stop() {
+	r.stopOnce.Do(func() { close(r.done) })
+}
+
+// read blocks until the next frame is complete, like next. Several reads may
+// be pending, e.g. the read of a program replaced by a new one. The frame
+// goes to the latest read, the reads before return with ok false.
+func (r *rxReader) read() (rxFrame, bool) {
+	return r.reads.Wait(func() rxFrame {
+		data, kind, err := r.next()
+		return rxFrame{data, kind, err}
+	})
+}
+
+// configure changes the framing, idle flush time and maximum line length.
+// The new settings apply to all data not yet returned by next.
+func (r *rxReader) configure(settings Settings) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.settings = settings
+}
+
+func (r *rxReader) getSettings() Settings {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.settings
+}
+
+// next blocks until the next frame is complete and returns it together with
+// its kind.
+// If idle flush is enabled, unterminated data is returned as partial frame
+// after the idle time. The complete frame containing the partial data is
+// returned later as usual.
+// Frames longer than the maximum line length are returned in parts, all but
+// the last part are split frames.
+func (r *rxReader) next() (frame []byte, kind int, err error) {
+	for {
+		settings := r.getSettings()
+		framing, idleFlush := settings.Framing, settings.IdleFlush
+
+		if frame, ok := r.splitter.split(framing, settings.MaxLineLength); ok {
+			r.partialLen = 0
+			return frame, splitFrame, nil
+		}
+		if frame, ok := r.splitter.next(framing); ok {
+			r.partialLen = 0
+			return frame, completeFrame, nil
+		}
+		if r.err != nil {
+			// like bufio.Scanner, return unterminated data before the error
+			if len(r.splitter.buf) > 0 {
+				r.partialLen = 0
+				return r.splitter.flush(), completeFrame, nil
+			}
+			return nil, completeFrame, r.err
+		}
+
+		var timeout <-chan time.Time
+		var timer *time.Timer
+		switch {
+		case framing.kind == timeoutFraming && len(r.splitter.buf) > 0:
+			timer = time.NewTimer(framing.timeout)
+			timeout = timer.C
+
+		case framing.kind != timeoutFraming && idleFlush > 0 && len(r.splitter.buf) > r.partialLen:
+			timer = time.NewTimer(idleFlush)
+			timeout = timer.C
+		}
+
+		select {
+		case chunk := <-r.chunks:
+			if chunk.err != nil {
+				r.err = chunk.err
+			} else {
+				r.splitter.buf = append(r.splitter.buf, chunk.data...)
+			}
+
+		case <-timeout:
+			if framing.kind == timeoutFraming {
+				return r.splitter.flush(), completeFrame, nil
+			}
+			r.partialLen = len(r.splitter.buf)
+			return bytes.Clone(r.splitter.buf), partialFrame, nil
+
+		case <-r.done:
+			return nil, completeFrame, io.EOF
+		}
+
+		if timer != nil {
+			timer.Stop()
+		}
+	}
+}
@@ -0,0 +1,145 @@
+package session
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+// readFrames feeds the chunks into a reader with the given framing and
+// settings and returns the received frames joined by "|".
+// Partial frames are marked with a trailing "~", split frames with a "+".
+func readFrames(t *testing.T, spec string, settings Settings, chunks ...string) string {
+	t.Helper()
+
+	var err error
+	settings.Framing, err = ParseFraming(spec)
+	if err != nil {
+		t.Fatalf("ParseFraming(%q) failed: %v", spec, err)
+	}
+
+	pr, pw := io.Pipe()
+	rx := newRxReader(pr, settings)
+	defer rx.stop()
+
+	go func() {
+		for _, chunk := range chunks {
+			pw.Write([]byte(chunk))
+			time.Sleep(20 * time.Millisecond)
+		}
+		pw.Close()
+	}()
+
+	var frames []string
+	for {
+		frame, kind, err := rx.next()
+		if err != nil {
+			break
+		}
+		switch kind {
+		case partialFrame:
+			frames = append(frames, string(frame)+"~")
+		case splitFrame:
+			frames = append(frames, string(frame)+"+")
+		default:
+			frames = append(frames, string(frame))
+		}
+	}
+	return strings.Join(frames, "|")
+}
+
+func TestFraming(t *testing.T) {
+	tests := []struct {
+		spec   string
+		chunks []string
+		want   string
+	}{
+		{"lf", []string{"one\r\ntw", "o\nthree\n"}, "one|two|three"},
+		{"cr", []string{"one\rtwo\n\r"}, "one|two\n"},
+		{"crlf", []string{"one\ntwo\r", "\nthree\r\n"}, "one\ntwo|three"},
+		{"any", []string{"one\r", "\ntwo\rthree\n\n"}, "one|two|three|"},
+		{`delim:\x03`, []string{"\x02one\x03\x02two\x03"}, "\x02one|\x02two"},
+		{"fixed:4", []string{"aaaab", "bbbcc"}, "aaaa|bbbb|cc"},
+		{"timeout:10ms", []string{"one", "two"}, "one|two"},
+	}
+
+	for _, tt := range tests {
+		if got := readFrames(t, tt.spec, Settings{}, tt.chunks...); got != tt.want {
+			t.Errorf("framing %q: frames = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestIdleFlush(t *testing.T) {
+	// Unterminated data is returned as partial frame once per idle period,
+	// the complete line follows when its line ending arrives.
+	got := readFrames(t, "lf", Settings{IdleFlush: 5 * time.Millisecond}, "log", "in: ", "root\n")
+	if want := "log~|login: ~|login: root"; got != want {
+		t.Errorf("frames = %q, want %q", got, want)
+	}
+}
+
+func TestMaxLineLength(t *testing.T) {
+	tests := []struct {
+		spec   string
+		chunks []string
+		want   string
+	}{
+		{"lf", []string{"aaaabbbbcc\n"}, "aaaa+|bbbb+|cc"},
+		// wait for the byte after the cut, it may end the line
+		{"lf", []string{"aaaa", "\r\n"}, "aaaa"},
+		{"crlf", []string{"aaa\r", "\nbb"}, "aaa|bb"},
+		// do not cut UTF-8 sequences
+		{"lf", []string{"aaa\u00e4b\n"}, "aaa+|\u00e4b"},
+		{"fixed:6", []string{"aaaaaabb"}, "aaaaaa|bb"},
+	}
+
+	for _, tt := range tests {
+		if got := readFrames(t, tt.spec, Settings{MaxLineLength: 4}, tt.chunks...); got != tt.want {
+			t.Errorf("framing %q: frames = %q, want %q", tt.spec, got, tt.want)
+		}
+	}
+}
+
+func TestParseFramingInvalid(t *testing.T) {
+	for _, spec := range []string{"", "foo", "fixed:0", "fixed:x", "timeout:1", "delim:", `delim:\q`} {
+		if _, err := ParseFraming(spec); err == nil {
+			t.Errorf("ParseFraming(%q) succeeded, want error", spec)
+		}
+	}
+}
+
+// A read started while another one is pending, like the first read of the
+// program restarted after the editor, gets the next frame. The pending read
+// returns without frame.
+func TestReadHandover(t *testing.T) {
+	settings := Settings{Framing: DefaultFraming}
+	pr, pw := io.Pipe()
+	rx := newRxReader(pr, settings)
+	defer rx.stop()
+
+	type result struct {
+		frame rxFrame
+		ok    bool
+	}
+	first, second := make(chan result), make(chan result)
+	go func() {
+		frame, ok := rx.read()
+		first <- result{frame, ok}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	go func() {
+		frame, ok := rx.read()
+		second <- result{frame, ok}
+	}()
+	time.Sleep(20 * time.Millisecond)
+	pw.Write([]byte("hello\n"))
+
+	if res := <-second; !res.ok || string(res.frame.data) != "hello" {
+		t.Errorf("second read = %q, %v, want hello", res.frame.data, res.ok)
+	}
+	if res := <-first; res.ok {
+		t.Errorf("first read got %q, want nothing", res.frame.data)
+	}
+}
@@ -1,8 +1,8 @@
 package session
 
 import (
-	"bufio"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log"
@@ -16,6 +16,7 @@ import (
 	tea "github.com/charmbracelet/bubbletea"
 	zone "github.com/lrstanley/bubblezone"
 	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/escape"
 	"github.com/mahlburgc/teaterm/internal/keymap"
 	"github.com/mahlburgc/teaterm/internal/styles"
 	"go.bug.st/serial"
@@ -23,11 +24,14 @@ import (
 )
 
 // Port is an interface that matches io.ReadWriteCloser.
-// This interface is used to utilize either a real serial port
-// of a mocked serial port for development.
-// Both serial.Port and our mockPort implement this interface.
+// This interface is used to utilize either a real serial port, a network
+// port, a child process or a mocked serial port for development.
+// serial.Port, netPort, execPort and our mockPort implement this interface.
 type Port io.ReadWriteCloser
 
+// time between two reconnect tries
+const reconnectInterval = 1 * time.Second
+
 const (
 	disconnected = iota
 	connecting
@@ -42,46 +46,92 @@ type (
 	startNextReconnectTryMsg bool
 )
 
+// ChangeSettingsMsg requests to apply new settings to the running session.
+type ChangeSettingsMsg struct {
+	Settings Settings
+}
+
+// modeSetter is implemented by ports that can change their serial mode
+// without being reopened, e.g. serial.Port.
+type modeSetter interface {
+	SetMode(mode *serial.Mode) error
+}
+
 type Model struct {
 	port             *io.ReadWriteCloser
-	scanner          *bufio.Scanner
-	selectedPort     string
-	selectedMode     *serial.Mode
+	rx               *rxReader
+	settings         Settings
 	status           int
 	sp               spinner.Model
 	ctx              context.Context
 	cancel           context.CancelFunc
 	showFullPortName bool
+	splitting        bool // a received line is split, the end is not yet received
+	mirror           *mirror
 }
 
-func New(port *io.ReadWriteCloser, selectedPort string, selectedMode *serial.Mode) (m Model) {
+func New(port *io.ReadWriteCloser, settings Settings) (m Model) {
 	sp := spinner.New()
 	sp.Spinner = spinner.Dot
 	sp.Style = styles.SpinnerStyle
 
-	scanner := bufio.NewScanner(*port)
+	if settings.Framing.spec == "" {
+		settings.Framing = DefaultFraming
+	}
+
+	mirror := &mirror{}
+	rx := newRxReader(mirrorReader{*port, mirror}, settings)
 	ctx, cancel := context.WithCancel(context.Background())
 
 	return Model{
 		port:             port,
-		scanner:          scanner,
-		selectedPort:     selectedPort,
-		selectedMode:     selectedMode,
+		rx:               rx,
+		settings:         settings,
 		status:           connected,
 		sp:               sp,
 		ctx:              ctx,
 		cancel:           cancel,
 		showFullPortName: false,
+		mirror:           mirror,
 	}
 }
 
+// Connect creates a session for a port that is not open yet. The port is
+// opened in the background and retried like after a connection loss.
+func Connect(settings Settings) Model {
+	var port io.ReadWriteCloser = closedPort{}
+	m := New(&port, settings)
+	m.status = connecting
+	return m
+}
+
+// closedPort is the port of a session until the first connect.
+type closedPort struct{}
+
+func (closedPort) Read([]byte) (int, error)  { return 0, io.EOF }
+func (closedPort) Write([]byte) (int, error) { return 0, errors.New("port not open") }
+func (closedPort) Close() error              { return nil }
+
 func (m Model) Init() tea.Cmd {
-	if m.status == connected {
-		return m.ReadFromPort(m.ctx)
+	switch m.status {
+	case connected:
+		return tea.Batch(m.ReadFromPort(m.ctx), m.readMirror(), m.mirrorInfo())
+	case connecting:
+		return m.prepareReconnect(0)
 	}
 	return nil
 }
 
+// Close stops the session and closes the port, e.g. when its tab is closed.
+func (m *Model) Close() {
+	m.status = disconnected
+	if m.cancel != nil {
+		m.cancel()
+	}
+	m.rx.stop()
+	(*m.port).Close()
+}
+
 func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 	var cmd tea.Cmd
 
@@ -94,7 +144,7 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				cmd = func() tea.Msg {
 					return events.ConnectionStatusMsg{Status: events.Connecting}
 				}
-				return m, tea.Batch(m.prepareReconnect(), cmd)
+				return m, tea.Batch(m.prepareReconnect(0), cmd)
 			} else {
 				m.status = disconnected
 				if m.cancel != nil {
@@ -103,14 +153,36 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 				cmd = func() tea.Msg {
 					return events.ConnectionStatusMsg{Status: events.Disconnected}
 				}
+				infoCmd := func() tea.Msg {
+					return events.InfoMsg("Port closed manually")
+				}
+				m.rx.stop()
 				(*m.port).Close()
-				return m, cmd
+				return m, tea.Batch(cmd, infoCmd)
 			}
 		}
 
+	case ChangeSettingsMsg:
+		return m, m.applySettings(msg.Settings)
+
 	case events.SerialRxMsgReceived:
+		m.splitting = false
 		return m, m.ReadFromPort(m.ctx)
 
+	case events.SerialRxPartialMsg:
+		return m, m.ReadFromPort(m.ctx)
+
+	case events.SerialRxSplitMsg:
+		if m.splitting {
+			return m, m.ReadFromPort(m.ctx)
+		}
+		m.splitting = true
+		maxLineLength := m.settings.MaxLineLength
+		infoCmd := func() tea.Msg {
+			return events.InfoMsg(fmt.Sprintf("Received line longer than %d bytes, it is shown split into several messages", maxLineLength))
+		}
+		return m, tea.Batch(m.ReadFromPort(m.ctx), infoCmd)
+
 	case spinner.TickMsg:
 		if m.status == connecting {
 			m.sp, cmd = m.sp.Update(msg)
@@ -118,28 +190,38 @@ func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
 		}
 
 	case events.SendMsg:
-		return m, m.sendToPort(msg.Data)
+		return m, m.sendToPort(msg)
+
+	case events.ForwardMsg:
+		return m, m.forwardToPort(msg)
+
+	case mirrorRxMsg:
+		return m, m.forwardMirrorData(msg)
+
+	case mirrorTxMsg:
+		return m, m.mirrorDataForwarded(msg)
 
 	case portReconnectedStatusMsg:
 		if msg.ok {
 			return m, m.handlePortReconnected(msg.port)
 		} else {
-			cmd = func() tea.Msg {
-				time.Sleep(1 * time.Second)
-				return startNextReconnectTryMsg(true)
-			}
-			return m, cmd
+			return m, nextReconnectTry(reconnectInterval)
 		}
 
 	case startNextReconnectTryMsg:
+		// the port is closed already, just try to open it again
 		if m.status != disconnected {
-			return m, tea.Batch(m.prepareReconnect())
+			return m, reconnectToPort(m.settings)
 		}
 
 	case events.ErrMsg:
 		switch msg := msg.(type) {
 		case *serial.PortError:
 			return m, m.HandleSerialPortErr(msg)
+		case *ConnectionLostError:
+			// A server or command may end the connection right after it
+			// was opened, wait before the next try.
+			return m, m.startReconnect(reconnectInterval)
 		}
 
 	case tea.MouseMsg:
@@ -158,26 +240,44 @@ func (m Model) View() string {
 	var status string
 
 	switch m.status {
-	case connected:
-		status = fmt.Sprintf(" %s ", styles.ConnectSymbolStyle.Render("●"))
-
-	case disconnected:
-		status = fmt.Sprintf(" %s ", styles.DisconnectedSymbolStyle.Render("●"))
+	case connected, disconnected:
+		status = fmt.Sprintf(" %s ", m.StatusSymbol())
 
 	case connecting:
-		status = fmt.Sprintf(" %s", m.sp.View())
+		status = fmt.Sprintf(" %s", m.StatusSymbol())
 	}
 
-	portname := m.selectedPort
-	if !m.showFullPortName && len(m.selectedPort) > 14 {
-		portname = "..." + m.selectedPort[len(m.selectedPort)-11:]
+	portname := m.settings.Port
+	if !m.showFullPortName {
+		portname = ShortPortName(portname)
 	}
 
-	status += styles.FooterStyle.Render(portname)
+	status += styles.FooterStyle.Render(portname + " " + FormatMode(m.settings.Mode) +
+		" " + FormatLineEnding(m.settings.LineEnding))
 
 	return zone.Mark("session", status)
 }
 
+// StatusSymbol returns the connection dot, or the spinner while connecting.
+func (m Model) StatusSymbol() string {
+	switch m.status {
+	case connected:
+		return styles.ConnectSymbolStyle.Render("●")
+	case disconnected:
+		return styles.DisconnectedSymbolStyle.Render("●")
+	}
+	return m.sp.View()
+}
+
+// ShortPortName shortens long port names like /dev/serial/by-id links to
+// their end.
+func ShortPortName(port string) string {
+	if len(port) > 14 {
+		return "..." + port[len(port)-11:]
+	}
+	return port
+}
+
 func ListPorts() {
 	ports, err := enumerator.GetDetailedPortsList()
 	if err != nil {
@@ -209,77 +309,210 @@ func ListPorts() {
 	}
 }
 
-// Open a port and return the port and the serial mode.
-func OpenPort(portname string) (Port, serial.Mode) {
-	mode := serial.Mode{
-		BaudRate: 115200, // TODO make configurable
+// openPort opens a serial port, a network port like tcp://host:port, a Unix
+// domain socket (unix:/path) or the stdio of a command (exec:command).
+func openPort(settings Settings) (Port, error) {
+	switch {
+	case isNetPort(settings.Port):
+		return openNetPort(settings.Port, &settings.Mode)
+	case strings.HasPrefix(settings.Port, "unix:"):
+		return openUnixPort(settings.Port)
+	case strings.HasPrefix(settings.Port, "exec:"):
+		return openExecPort(settings.Port)
 	}
-	port, err := serial.Open(portname, &mode)
+	return serial.Open(settings.Port, &settings.Mode)
+}
+
+// Open the port with the given settings.
+func OpenPort(settings Settings) Port {
+	port, err := openPort(settings)
 	if err != nil {
-		fmt.Printf("%s: %s\n", portname, err.Error())
+		fmt.Printf("%s: %s\n", settings.Port, err.Error())
 		os.Exit(1)
 	}
-	return port, mode
+	return port
 }
 
 // Returns a tea command that tries to reconnect to the serial port we connected
-// to on startup.
-func reconnectToPort(selectedPort string, selectedMode *serial.Mode) tea.Cmd {
+// to on startup, using the same settings.
+func reconnectToPort(settings Settings) tea.Cmd {
 	return func() tea.Msg {
-		port, err := serial.Open(selectedPort, selectedMode)
+		port, err := openPort(settings)
 		if err != nil {
-			log.Println("Failed to reconnect to port " + selectedPort)
+			log.Println("Failed to reconnect to port " + settings.Port)
 		}
 		return portReconnectedStatusMsg{port: port, ok: err == nil}
 	}
 }
 
-// Returns a Tea command to scan for a new receive message on the serial port.
+// Returns a Tea command to read the next received frame from the serial port.
+// How received data is split into frames is defined by the session framing.
+// Unterminated data is returned as partial message after the idle flush time.
 // The tea command returns the received message or error, if occured.
 func (m Model) ReadFromPort(ctx context.Context) tea.Cmd {
-	scn := m.scanner
+	rx := m.rx
 
 	return func() tea.Msg {
-		for scn.Scan() {
-			line := scn.Text()
-			return events.SerialRxMsgReceived(line)
+		// A second read, e.g. of the new program after the editor restart or
+		// after a late rx message of a replaced reader, takes over the frame
+		// of the pending one.
+		frame, ok := rx.read()
+		if !ok {
+			return nil
+		}
+		err := frame.err
+		if err == nil {
+			switch frame.kind {
+			case partialFrame:
+				return events.SerialRxPartialMsg(frame.data)
+			case splitFrame:
+				return events.SerialRxSplitMsg(frame.data)
+			}
+			return events.SerialRxMsgReceived(frame.data)
 		}
 
-		// Check if we manually canceled the context (Manual Disconnect)
+		// Check if we manually canceled the context (manual disconnect or settings change)
 		select {
 		case <-ctx.Done():
-			return events.InfoMsg("Port closed manually")
+			return nil
 		default:
 			// Context is still active, proceed to check actual errors
 		}
 
-		if err := m.scanner.Err(); err != nil {
-			if err != io.EOF && err != context.Canceled {
-				return events.ErrMsg(err)
-			}
+		if err != io.EOF && err != context.Canceled {
+			return events.ErrMsg(err)
 		}
 		return nil
 	}
 }
 
+// EncodeInput converts data typed in the given input mode into the bytes
+// to send. The line ending is not part of the result.
+func EncodeInput(data string, mode events.InputMode) (string, error) {
+	switch mode {
+	case events.EscapeMode:
+		return escape.Unescape(data)
+	case events.HexMode:
+		return escape.ParseHex(data)
+	default:
+		return data, nil
+	}
+}
+
 // Returns a Tea command to send a message string to the serial port.
+// The message is converted according to the input mode. In text mode the
+// configured line ending is appended to the message.
 // The tea command returns the transmitted message or error, if occured.
-func (m Model) sendToPort(msg string) tea.Cmd {
+func (m Model) sendToPort(send events.SendMsg) tea.Cmd {
+	lineEnding := m.settings.LineEnding
+	if send.Mode != events.TextMode {
+		lineEnding = ""
+	}
 	return func() tea.Msg {
-		stringToSend := msg + "\r\n" // TODO add custom Lineending
-		_, err := (*m.port).Write([]byte(stringToSend))
+		msg, err := EncodeInput(send.Data, send.Mode)
 		if err != nil {
-			return events.ErrMsg(err)
+			return events.ErrMsg(&SendError{Err: err, Tag: send.Tag})
+		}
+		_, err = (*m.port).Write([]byte(msg + lineEnding))
+		if err != nil {
+			return events.ErrMsg(&SendError{Err: err, Tag: send.Tag})
+		}
+		return events.SerialTxMsg{Data: msg, LineEnding: lineEnding, Tag: send.Tag}
+	}
+}
+
+// SendError is the error of a send message that was not written to the port.
+// Every send message ends with a SerialTxMsg or a SendError.
+type SendError struct {
+	Err error
+	Tag string // tag of the send message
+}
+
+func (e *SendError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *SendError) Unwrap() error {
+	return e.Err
+}
+
+// Returns a Tea command to send a line of another program to the port with
+// the configured line ending. Lines are dropped while the port is not
+// connected.
+func (m Model) forwardToPort(msg events.ForwardMsg) tea.Cmd {
+	if m.status != connected {
+		return func() tea.Msg {
+			return events.InfoMsg(fmt.Sprintf("Port not connected, data of %s dropped", msg.Source))
 		}
-		return nil
+	}
+	port, lineEnding := *m.port, m.settings.LineEnding
+	return func() tea.Msg {
+		if _, err := port.Write([]byte(msg.Data + lineEnding)); err != nil {
+			// wrapped, a failed write must not start a reconnect
+			return events.ErrMsg(fmt.Errorf("%s: %w", msg.Source, err))
+		}
+		return events.ForwardedTxMsg{Data: msg.Data, LineEnding: lineEnding, Source: msg.Source}
 	}
 }
 
-// Prepare TUI to reconnect
-func (m *Model) prepareReconnect() tea.Cmd {
+// Apply new settings to the session.
+// If only the serial mode changed, it is set on the open port. Otherwise the
+// port is closed and reopened with the new settings. Disconnected sessions just
+// store the settings for the next connect. If the port refuses the new mode,
+// the session keeps its settings.
+func (m *Model) applySettings(settings Settings) tea.Cmd {
+	old := m.settings
+	reopen := false
+
+	// e.g. only the line ending changed, nothing to do on the port
+	if m.status == connected && (old.Port != settings.Port || !sameMode(old.Mode, settings.Mode)) {
+		if setter, ok := (*m.port).(modeSetter); ok && old.Port == settings.Port {
+			if err := setter.SetMode(&settings.Mode); err != nil {
+				return func() tea.Msg {
+					return events.ErrMsg(err)
+				}
+			}
+		} else {
+			reopen = true
+		}
+	}
+
+	m.settings = settings
+	m.rx.configure(settings)
+
+	infoCmd := func() tea.Msg {
+		return events.InfoMsg(fmt.Sprintf("Port settings changed: %s %s, TX line ending %s, RX framing %s",
+			settings.Port, FormatMode(settings.Mode), FormatLineEnding(settings.LineEnding), settings.Framing))
+	}
+	if !reopen {
+		return infoCmd
+	}
+
+	// Controlled close and reopen. Cancel the context first, so the pending
+	// read does not report the closed port as connection loss.
+	if m.cancel != nil {
+		m.cancel()
+	}
+	conStatusCmd := func() tea.Msg {
+		return events.ConnectionStatusMsg{Status: events.Connecting}
+	}
+	return tea.Batch(m.prepareReconnect(0), conStatusCmd, infoCmd)
+}
+
+// GetSettings returns the current session settings.
+func (m Model) GetSettings() Settings {
+	return m.settings
+}
+
+// Prepare TUI to reconnect. The first try is made after the delay.
+func (m *Model) prepareReconnect(delay time.Duration) tea.Cmd {
 	m.status = connecting
+	m.rx.stop()
 	(*m.port).Close()
-	startReconnectCmd := reconnectToPort(m.selectedPort, m.selectedMode)
+	startReconnectCmd := reconnectToPort(m.settings)
+	if delay > 0 {
+		startReconnectCmd = nextReconnectTry(delay)
+	}
 	spinnerCmd := m.sp.Tick
 	return tea.Batch(startReconnectCmd, spinnerCmd)
 }
@@ -289,7 +522,7 @@ func (m *Model) handlePortReconnected(port Port) tea.Cmd {
 	log.Println("Port reconnected")
 	m.status = connected
 	*m.port = port
-	m.scanner = bufio.NewScanner(*m.port)
+	m.rx = newRxReader(mirrorReader{*m.port, m.mirror}, m.settings)
 
 	if m.cancel != nil {
 		m.cancel()
@@ -312,12 +545,26 @@ func (m *Model) handlePortReconnected(port Port) tea.Cmd {
 // and start the reconnect spinner symbol.
 func (m *Model) HandleSerialPortErr(msg *serial.PortError) tea.Cmd {
 	if msg.Code() == serial.PortClosed {
-		if m.status != disconnected {
-			cmd := func() tea.Msg {
-				return events.ConnectionStatusMsg{Status: events.Connecting}
-			}
-			return tea.Batch(m.prepareReconnect(), cmd)
-		}
+		return m.startReconnect(0)
 	}
 	return nil
 }
+
+// Start trying to reconnect to a lost port after the delay, unless the port
+// was closed manually.
+func (m *Model) startReconnect(delay time.Duration) tea.Cmd {
+	if m.status == disconnected {
+		return nil
+	}
+	cmd := func() tea.Msg {
+		return events.ConnectionStatusMsg{Status: events.Connecting}
+	}
+	return tea.Batch(m.prepareReconnect(delay), cmd)
+}
+
+// Returns a tea command that starts the next reconnect try after the delay.
+func nextReconnectTry(delay time.Duration) tea.Cmd {
+	return tea.Tick(delay, func(time.Time) tea.Msg {
+		return startNextReconnectTryMsg(true)
+	})
+}
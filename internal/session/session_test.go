@@ -0,0 +1,38 @@
+package session
+
+import (
+	"errors"
+	"io"
+	"testing"
+
+	"go.bug.st/serial"
+)
+
+// modePort is a port that refuses every serial mode.
+type modePort struct {
+	io.ReadWriteCloser
+}
+
+func (modePort) SetMode(*serial.Mode) error {
+	return errors.New("mode not supported")
+}
+
+func TestApplySettingsModeRefused(t *testing.T) {
+	r, w := io.Pipe()
+	defer w.Close()
+	port := io.ReadWriteCloser(modePort{struct {
+		io.Reader
+		io.WriteCloser
+	}{r, w}})
+	settings := Settings{Port: "/dev/ttyTEST", Mode: serial.Mode{BaudRate: 115200}}
+	m := New(&port, settings)
+	defer m.Close()
+
+	changed := settings
+	changed.Mode.BaudRate = 9600
+	m, _ = m.Update(ChangeSettingsMsg{Settings: changed})
+
+	if got := m.GetSettings().Mode.BaudRate; got != 115200 {
+		t.Errorf("baud rate = %d after refused mode, want 115200", got)
+	}
+}
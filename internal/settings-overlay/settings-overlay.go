@@ -0,0 +1,257 @@
+// Package settings provides a dialog to change the port settings of a running session.
+// On apply, the dialog validates all fields and broadcasts a session.ChangeSettingsMsg.
+// Opened for a new tab, it broadcasts a NewTabMsg instead.
+package settings
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/charmbracelet/bubbles/key"
+	"github.com/charmbracelet/bubbles/textinput"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+const (
+	portField = iota
+	baudRateField
+	dataBitsField
+	parityField
+	stopBitsField
+	lineEndingField
+	framingField
+)
+
+// field is either a free text field or a choice field, if choices are set.
+type field struct {
+	label   string
+	input   textinput.Model
+	choices []string
+	choice  int
+}
+
+type Model struct {
+	fields   []field
+	selected int
+	current  session.Settings // settings the dialog was opened with
+	newTab   bool             // the settings are used for a new tab
+	err      error
+}
+
+// NewTabMsg requests to open a session with the given settings in a new tab.
+type NewTabMsg struct {
+	Settings session.Settings
+}
+
+func New() (m Model) {
+	m.fields = []field{
+		portField:       {label: "Port", input: newInput()},
+		baudRateField:   {label: "Baud rate", input: newInput()},
+		dataBitsField:   {label: "Data bits", choices: []string{"5", "6", "7", "8"}},
+		parityField:     {label: "Parity", choices: []string{"none", "odd", "even", "mark", "space"}},
+		stopBitsField:   {label: "Stop bits", choices: []string{"1", "1.5", "2"}},
+		lineEndingField: {label: "Line ending", input: newInput()},
+		framingField:    {label: "RX framing", input: newInput()},
+	}
+	return m
+}
+
+func newInput() textinput.Model {
+	ti := textinput.New()
+	ti.Prompt = ""
+	ti.Width = 30
+	ti.Cursor.Style = styles.CursorStyle
+	return ti
+}
+
+// Open loads the given settings into the dialog and selects the first field.
+func (m *Model) Open(settings session.Settings) tea.Cmd {
+	m.current = settings
+	m.newTab = false
+	m.err = nil
+
+	m.fields[portField].input.SetValue(settings.Port)
+	m.fields[baudRateField].input.SetValue(strconv.Itoa(settings.Mode.BaudRate))
+
+	dataBits := settings.Mode.DataBits
+	if dataBits == 0 {
+		dataBits = 8
+	}
+	m.fields[dataBitsField].setChoice(strconv.Itoa(dataBits))
+	m.fields[parityField].choice = int(settings.Mode.Parity)
+	m.fields[stopBitsField].choice = int(settings.Mode.StopBits)
+	m.fields[lineEndingField].input.SetValue(session.FormatLineEnding(settings.LineEnding))
+	m.fields[framingField].input.SetValue(settings.Framing.String())
+
+	return m.selectField(0)
+}
+
+// OpenNewTab opens the dialog to enter the settings of a new tab, prefilled
+// with the given settings.
+func (m *Model) OpenNewTab(settings session.Settings) tea.Cmd {
+	cmd := m.Open(settings)
+	m.newTab = true
+	return cmd
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	var cmd tea.Cmd
+
+	keyMsg, ok := msg.(tea.KeyMsg)
+	if !ok {
+		// forward e.g. cursor blink messages to the focused text field
+		f := &m.fields[m.selected]
+		if f.choices == nil {
+			f.input, cmd = f.input.Update(msg)
+		}
+		return m, cmd
+	}
+
+	f := &m.fields[m.selected]
+
+	switch {
+	case key.Matches(keyMsg, keymap.Default.SendKey):
+		return m, m.apply()
+
+	case keyMsg.Type == tea.KeyUp || keyMsg.Type == tea.KeyShiftTab:
+		return m, m.selectField((m.selected + len(m.fields) - 1) % len(m.fields))
+
+	case keyMsg.Type == tea.KeyDown || keyMsg.Type == tea.KeyTab:
+		return m, m.selectField((m.selected + 1) % len(m.fields))
+
+	case f.choices != nil && keyMsg.Type == tea.KeyLeft:
+		f.choice = (f.choice + len(f.choices) - 1) % len(f.choices)
+
+	case f.choices != nil && keyMsg.Type == tea.KeyRight:
+		f.choice = (f.choice + 1) % len(f.choices)
+
+	case f.choices == nil:
+		f.input, cmd = f.input.Update(msg)
+	}
+
+	return m, cmd
+}
+
+func (m Model) View() string {
+	labelStyle := lipgloss.NewStyle().Width(12)
+
+	var rows []string
+	title := "Port Settings"
+	if m.newTab {
+		title = "New Tab"
+	}
+	rows = append(rows, title+"\n")
+	for i, f := range m.fields {
+		var value string
+		if f.choices != nil {
+			value = fmt.Sprintf("‹ %s ›", f.choices[f.choice])
+		} else {
+			value = f.input.View()
+		}
+
+		label := labelStyle.Render(f.label)
+		if i == m.selected {
+			label = labelStyle.Inherit(styles.FocusedPromtStyle).Render(f.label)
+		}
+		rows = append(rows, label+value)
+	}
+
+	rows = append(rows, "")
+	if m.err != nil {
+		rows = append(rows, styles.ErrMsgStyle.Render("ERROR: "+m.err.Error()))
+	}
+	rows = append(rows, styles.HelpDesc.Render("enter apply • esc cancel • ↑/↓ select • ←/→ change"))
+
+	return styles.HelpOverlayBorderStyle.Render(strings.Join(rows, "\n"))
+}
+
+// Select the field with the given index and focus it, if it is a text field.
+func (m *Model) selectField(i int) tea.Cmd {
+	m.fields[m.selected].input.Blur()
+	m.selected = i
+	if m.fields[i].choices == nil {
+		m.fields[i].input.CursorEnd()
+		return m.fields[i].input.Focus()
+	}
+	return nil
+}
+
+// Validate the dialog fields and return a command broadcasting the new settings.
+func (m *Model) apply() tea.Cmd {
+	settings, err := m.settings()
+	m.err = err
+	if err != nil {
+		return nil
+	}
+	if m.newTab {
+		return func() tea.Msg {
+			return NewTabMsg{Settings: settings}
+		}
+	}
+	return func() tea.Msg {
+		return session.ChangeSettingsMsg{Settings: settings}
+	}
+}
+
+// Build the session settings from the dialog fields.
+// Settings not shown in the dialog are kept.
+func (m *Model) settings() (session.Settings, error) {
+	settings := m.current
+
+	settings.Port = strings.TrimSpace(m.fields[portField].input.Value())
+	if settings.Port == "" {
+		return session.Settings{}, fmt.Errorf("port must not be empty")
+	}
+
+	baudRate, err := strconv.Atoi(strings.TrimSpace(m.fields[baudRateField].input.Value()))
+	if err != nil {
+		return session.Settings{}, fmt.Errorf("invalid baud rate %q", m.fields[baudRateField].input.Value())
+	}
+
+	dataBits, _ := strconv.Atoi(m.fields[dataBitsField].value())
+
+	// keep the initial modem status bits, they can not be changed here
+	dtr, rts := true, true
+	if m.current.Mode.InitialStatusBits != nil {
+		dtr, rts = m.current.Mode.InitialStatusBits.DTR, m.current.Mode.InitialStatusBits.RTS
+	}
+
+	settings.Mode, err = session.NewMode(baudRate, dataBits, m.fields[parityField].value(),
+		m.fields[stopBitsField].value(), dtr, rts)
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	settings.LineEnding, err = session.ParseLineEnding(m.fields[lineEndingField].input.Value())
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	settings.Framing, err = session.ParseFraming(m.fields[framingField].input.Value())
+	if err != nil {
+		return session.Settings{}, err
+	}
+
+	return settings, nil
+}
+
+func (f field) value() string {
+	if f.choices != nil {
+		return f.choices[f.choice]
+	}
+	return f.input.Value()
+}
+
+func (f *field) setChoice(value string) {
+	for i, c := range f.choices {
+		if c == value {
+			f.choice = i
+			return
+		}
+	}
+}
@@ -0,0 +1,331 @@
+// Package share streams the session to TCP clients, like ser2net does for a
+// serial port. Clients get every message as a text line, like the text log
+// format. Clients of a read-write listener can also send lines to the port.
+package share
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/handover"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+// number of lines buffered per client, a slow client misses lines
+const clientBuffer = 1024
+
+// Listener is a TCP address clients connect to, given as "addr" or
+// "addr,rw". Clients of read-only listeners just watch.
+type Listener struct {
+	Addr      string
+	ReadWrite bool
+}
+
+// ParseListener parses a listener like ":4000", "0.0.0.0:4001,rw" or
+// "localhost:4002,ro".
+func ParseListener(spec string) (Listener, error) {
+	addr, policy, _ := strings.Cut(spec, ",")
+	l := Listener{Addr: addr}
+	switch policy {
+	case "", "ro":
+	case "rw":
+		l.ReadWrite = true
+	default:
+		return l, fmt.Errorf("invalid share policy %q in %q, use ro or rw", policy, spec)
+	}
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		return l, fmt.Errorf("invalid share address %q, use [host]:port[,ro|rw]", addr)
+	}
+	return l, nil
+}
+
+type client struct {
+	conn net.Conn
+	rw   bool
+	out  chan string
+}
+
+func (c *client) String() string {
+	if c.rw {
+		return c.conn.RemoteAddr().String() + " (rw)"
+	}
+	return c.conn.RemoteAddr().String()
+}
+
+// Server accepts the clients of all listeners. It is an io.Writer, the
+// written lines are sent to all clients.
+type Server struct {
+	port      string
+	listeners []net.Listener
+
+	mu      sync.Mutex // guards clients and closed
+	clients map[*client]bool
+	closed  bool
+
+	// lines of read-write clients for the tea program, lines are dropped
+	// if the program does not keep up
+	events chan tea.Msg
+	// signals a change of the client list to the tea program
+	changed chan struct{}
+	// the events go to the program restarted after the editor
+	waits handover.Latest[tea.Msg]
+	// the addresses are shown once, not on every restart
+	announce sync.Once
+}
+
+type (
+	// Sent when a client connected or disconnected.
+	clientsMsg []string
+	// A line sent by a read-write client.
+	clientTxMsg struct {
+		data   string
+		source string
+	}
+)
+
+// Start listens on all listeners. The port name is shown to the clients.
+func Start(listeners []Listener, port string) (*Server, error) {
+	s := &Server{
+		port:    port,
+		clients: make(map[*client]bool),
+		events:  make(chan tea.Msg, 64),
+		changed: make(chan struct{}, 1),
+	}
+	for _, l := range listeners {
+		ln, err := net.Listen("tcp", l.Addr)
+		if err != nil {
+			s.Close()
+			return nil, fmt.Errorf("share: %w", err)
+		}
+		s.listeners = append(s.listeners, ln)
+		go s.accept(ln, l.ReadWrite)
+	}
+	return s, nil
+}
+
+// Addrs returns the addresses the server listens on.
+func (s *Server) Addrs() []string {
+	var addrs []string
+	for _, ln := range s.listeners {
+		addrs = append(addrs, ln.Addr().String())
+	}
+	return addrs
+}
+
+func (s *Server) accept(ln net.Listener, rw bool) {
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		c := &client{conn: conn, rw: rw, out: make(chan string, clientBuffer)}
+		mode := "read-only"
+		if rw {
+			mode = "read-write"
+		}
+		c.out <- fmt.Sprintf("teaterm: sharing %s (%s)\n", s.port, mode)
+
+		s.mu.Lock()
+		if s.closed {
+			s.mu.Unlock()
+			conn.Close()
+			return
+		}
+		s.clients[c] = true
+		s.mu.Unlock()
+
+		go s.write(c)
+		go s.read(c)
+		s.notify()
+	}
+}
+
+// write sends the lines to the client until it is removed.
+func (s *Server) write(c *client) {
+	for line := range c.out {
+		if _, err := c.conn.Write([]byte(strings.ReplaceAll(line, "\n", "\r\n"))); err != nil {
+			s.remove(c)
+			return
+		}
+	}
+}
+
+// read forwards the lines of read-write clients until the client disconnects.
+// Read-only clients are read to notice the disconnect.
+func (s *Server) read(c *client) {
+	scanner := bufio.NewScanner(c.conn)
+	for scanner.Scan() {
+		if !c.rw {
+			continue
+		}
+		line := strings.TrimRight(scanner.Text(), "\r")
+		select {
+		case s.events <- clientTxMsg{data: line, source: c.conn.RemoteAddr().String()}:
+		default:
+			s.tell(c, fmt.Sprintf("teaterm: busy, line dropped: %s\n", line))
+		}
+	}
+	s.remove(c)
+}
+
+func (s *Server) remove(c *client) {
+	s.mu.Lock()
+	if !s.clients[c] {
+		s.mu.Unlock()
+		return
+	}
+	delete(s.clients, c)
+	close(c.out)
+	s.mu.Unlock()
+
+	c.conn.Close()
+	s.notify()
+}
+
+// notify tells the tea program that the client list changed. A change not
+// yet fetched by the program covers this one.
+func (s *Server) notify() {
+	select {
+	case s.changed <- struct{}{}:
+	default:
+	}
+}
+
+// clientList returns the connected clients, sorted.
+func (s *Server) clientList() clientsMsg {
+	s.mu.Lock()
+	var clients []string
+	for c := range s.clients {
+		clients = append(clients, c.String())
+	}
+	s.mu.Unlock()
+
+	sort.Strings(clients)
+	return clientsMsg(clients)
+}
+
+// nextEvent blocks until a client sends a line or the client list changes.
+// It returns nil, if a newer call takes the event.
+func (s *Server) nextEvent() tea.Msg {
+	msg, _ := s.waits.Wait(func() tea.Msg {
+		select {
+		case msg := <-s.events:
+			return msg
+		case <-s.changed:
+			return s.clientList()
+		}
+	})
+	return msg
+}
+
+// tell sends the data to the client only. It is dropped if the client does
+// not read fast enough.
+func (s *Server) tell(c *client, data string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.clients[c] {
+		select {
+		case c.out <- data:
+		default:
+		}
+	}
+}
+
+// Write sends the data to all clients. Lines are dropped for clients that do
+// not read fast enough.
+func (s *Server) Write(data []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for c := range s.clients {
+		select {
+		case c.out <- string(data):
+		default:
+		}
+	}
+	return len(data), nil
+}
+
+// Close stops all listeners and disconnects the clients.
+func (s *Server) Close() error {
+	for _, ln := range s.listeners {
+		ln.Close()
+	}
+	s.mu.Lock()
+	s.closed = true
+	clients := s.clients
+	s.clients = make(map[*client]bool)
+	s.mu.Unlock()
+
+	for c := range clients {
+		close(c.out)
+		c.conn.Close()
+	}
+	return nil
+}
+
+// Model shows the clients in the footer and passes the lines of read-write
+// clients to the session.
+type Model struct {
+	server  *Server
+	clients []string
+}
+
+// New creates the model of the server. A nil server creates a model that
+// shows nothing.
+func New(server *Server) Model {
+	return Model{server: server}
+}
+
+func (m Model) Init() tea.Cmd {
+	if m.server == nil {
+		return nil
+	}
+	var infoCmd tea.Cmd
+	m.server.announce.Do(func() {
+		info := fmt.Sprintf("Sharing the session on %s", strings.Join(m.server.Addrs(), ", "))
+		infoCmd = func() tea.Msg {
+			return events.InfoMsg(info)
+		}
+	})
+	return tea.Batch(infoCmd, m.waitForEvent())
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case clientsMsg:
+		m.clients = msg
+		return m, m.waitForEvent()
+
+	case clientTxMsg:
+		forwardCmd := func() tea.Msg {
+			return events.ForwardMsg{Data: msg.data, Source: msg.source}
+		}
+		return m, tea.Batch(forwardCmd, m.waitForEvent())
+	}
+	return m, nil
+}
+
+func (m Model) waitForEvent() tea.Cmd {
+	if m.server == nil {
+		return nil
+	}
+	return m.server.nextEvent
+}
+
+// View returns the connected clients for the footer.
+func (m Model) View() string {
+	if m.server == nil {
+		return ""
+	}
+	clients := "none"
+	if len(m.clients) > 0 {
+		clients = strings.Join(m.clients, ", ")
+	}
+	return styles.FooterStyle.Render(" | viewers: " + clients)
+}
@@ -0,0 +1,166 @@
+package share
+
+import (
+	"bufio"
+	"net"
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+)
+
+func TestParseListener(t *testing.T) {
+	tests := []struct {
+		spec string
+		want Listener
+		ok   bool
+	}{
+		{":4000", Listener{Addr: ":4000"}, true},
+		{"0.0.0.0:4001,rw", Listener{Addr: "0.0.0.0:4001", ReadWrite: true}, true},
+		{"localhost:4002,ro", Listener{Addr: "localhost:4002"}, true},
+		{"4000", Listener{}, false},
+		{":4000,x", Listener{}, false},
+	}
+	for _, tt := range tests {
+		got, err := ParseListener(tt.spec)
+		if (err == nil) != tt.ok || (tt.ok && got != tt.want) {
+			t.Errorf("ParseListener(%q) = %v, %v", tt.spec, got, err)
+		}
+	}
+}
+
+// next waits for the next event of the server.
+func next(t *testing.T, s *Server) any {
+	t.Helper()
+	events := make(chan tea.Msg, 1)
+	go func() { events <- s.nextEvent() }()
+	select {
+	case msg := <-events:
+		return msg
+	case <-time.After(time.Second):
+		t.Fatal("no event")
+		return nil
+	}
+}
+
+func TestServer(t *testing.T) {
+	s, err := Start([]Listener{{Addr: "127.0.0.1:0"}, {Addr: "127.0.0.1:0", ReadWrite: true}}, "/dev/ttyUSB0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	addrs := s.Addrs()
+
+	ro, err := net.Dial("tcp", addrs[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ro.Close()
+	next(t, s)
+	rw, err := net.Dial("tcp", addrs[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	m := New(s)
+	m, _ = m.Update(next(t, s))
+	if len(m.clients) != 2 || !strings.Contains(m.View(), "(rw)") {
+		t.Errorf("clients = %v", m.clients)
+	}
+
+	// all clients get the log lines
+	s.Write([]byte("rx line\n"))
+	r := bufio.NewReader(ro)
+	for _, want := range []string{"teaterm: sharing /dev/ttyUSB0 (read-only)\r\n", "rx line\r\n"} {
+		ro.SetReadDeadline(time.Now().Add(time.Second))
+		if line, _ := r.ReadString('\n'); line != want {
+			t.Errorf("read-only client got %q, want %q", line, want)
+		}
+	}
+
+	// only lines of read-write clients are sent
+	ro.Write([]byte("ignored\n"))
+	rw.Write([]byte("reboot\r\n"))
+	_, cmd := m.Update(next(t, s))
+	batch, _ := cmd().(tea.BatchMsg)
+	if len(batch) == 0 {
+		t.Fatal("line of the read-write client not forwarded")
+	}
+	forward, _ := batch[0]().(events.ForwardMsg)
+	if forward.Data != "reboot" || forward.Source != rw.LocalAddr().String() {
+		t.Errorf("forwarded %+v", forward)
+	}
+
+	rw.Close()
+	m, _ = m.Update(next(t, s))
+	if len(m.clients) != 1 {
+		t.Errorf("clients after disconnect = %v", m.clients)
+	}
+}
+
+func TestServerBusy(t *testing.T) {
+	s, err := Start([]Listener{{Addr: "127.0.0.1:0", ReadWrite: true}}, "/dev/ttyUSB0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	rw, err := net.Dial("tcp", s.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	next(t, s)
+
+	// nobody takes the lines, the reader must not block
+	for range cap(s.events) + 1 {
+		rw.Write([]byte("line\n"))
+	}
+	r := bufio.NewReader(rw)
+	r.ReadString('\n') // greeting
+	rw.SetReadDeadline(time.Now().Add(time.Second))
+	if line, _ := r.ReadString('\n'); line != "teaterm: busy, line dropped: line\r\n" {
+		t.Errorf("client got %q, want the dropped line", line)
+	}
+}
+
+// After the editor restarts the program, the events go to the new program
+// and the addresses are not shown again.
+func TestRestart(t *testing.T) {
+	s, err := Start([]Listener{{Addr: "127.0.0.1:0", ReadWrite: true}}, "/dev/ttyUSB0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+	m := New(s)
+
+	batch, _ := m.Init()().(tea.BatchMsg)
+	if len(batch) != 2 {
+		t.Fatalf("Init returned %d commands, want the info and the wait", len(batch))
+	}
+	old := make(chan tea.Msg, 1)
+	go func() { old <- batch[1]() }()
+	time.Sleep(20 * time.Millisecond)
+
+	restarted := make(chan tea.Msg, 1)
+	go func() { restarted <- m.Init()() }()
+	time.Sleep(20 * time.Millisecond)
+
+	rw, err := net.Dial("tcp", s.Addrs()[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rw.Close()
+	select {
+	case msg := <-restarted:
+		if _, ok := msg.(clientsMsg); !ok {
+			t.Errorf("new program got %#v, want the client list", msg)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("new program got no event")
+	}
+	if msg := <-old; msg != nil {
+		t.Errorf("old program got %#v", msg)
+	}
+}
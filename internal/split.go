@@ -0,0 +1,169 @@
+package internal
+
+import (
+	"fmt"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+// The split view shows the message logs of two tabs, side by side or
+// stacked. The input sends to the shown tab, the other pane shows its partner
+// tab. Both panes show timestamps of the same clock, so events of the two
+// ports can be compared.
+
+// share of the first pane in percent
+const (
+	defaultSplitRatio = 50
+	minSplitRatio     = 20
+	maxSplitRatio     = 80
+	splitRatioStep    = 10
+)
+
+type splitView struct {
+	on      bool
+	partner int  // id of the tab shown in the other pane
+	stacked bool // panes above each other instead of side by side
+	ratio   int  // share of the left or top pane in percent
+	pending bool // split with the tab opened by the new tab dialog
+}
+
+// partnerIndex returns the tab index of the other pane, or -1 if the view
+// is not split.
+func (m model) partnerIndex() int {
+	if !m.split.on {
+		return -1
+	}
+	return m.tabIndex(m.split.partner)
+}
+
+// startSplit shows the tab with the index in the other pane.
+func (m *model) startSplit(i int) {
+	m.split.on = true
+	m.split.partner = m.tabs[i].id
+	if m.split.ratio == 0 {
+		m.split.ratio = defaultSplitRatio
+	}
+	m.msglog.ForceTimestamps(true)
+	m.tabs[i].msglog.ForceTimestamps(true)
+	m.updateLayout()
+}
+
+// endSplit shows the tab of the input alone.
+func (m *model) endSplit() {
+	if p := m.partnerIndex(); p >= 0 {
+		m.tabs[p].msglog.ForceTimestamps(false)
+		m.tabs[p].msglog.SetTitle("Messages")
+	}
+	m.split.on = false
+	m.msglog.ForceTimestamps(false)
+	m.msglog.SetTitle("Messages")
+	m.updateLayout()
+}
+
+// toggleSplit splits the view with the next tab. With a single tab, the
+// dialog for a new tab is opened and the view is split once it is open.
+func (m *model) toggleSplit() tea.Cmd {
+	switch {
+	case m.split.on:
+		m.endSplit()
+	case len(m.tabs) > 1:
+		m.startSplit((m.active + 1) % len(m.tabs))
+	default:
+		m.split.pending = true
+		m.showHelp = false
+		m.showSettings = true
+		return m.settings.OpenNewTab(m.session.GetSettings())
+	}
+	return nil
+}
+
+// resizeSplit grows or shrinks the pane of the input by one step.
+func (m *model) resizeSplit(grow bool) {
+	if !m.split.on {
+		return
+	}
+	step := splitRatioStep
+	if !grow {
+		step = -step
+	}
+	if m.active > m.partnerIndex() {
+		// the input pane is the right or bottom one
+		step = -step
+	}
+	m.split.ratio = min(max(m.split.ratio+step, minSplitRatio), maxSplitRatio)
+	m.updateLayout()
+}
+
+// layoutPanes sets the size of the message logs of both panes.
+func (m *model) layoutPanes(height int) {
+	p := m.partnerIndex()
+	if p < 0 {
+		m.msglog.SetSize(m.width, height)
+		return
+	}
+
+	// sizes of the left or top pane and of the other one
+	w1, h1, w2, h2 := m.width*m.split.ratio/100, height, 0, height
+	if m.split.stacked {
+		w1, h1 = m.width, height*m.split.ratio/100
+		w2, h2 = m.width, height-h1
+	} else {
+		w2 = m.width - w1
+	}
+	if m.active > p {
+		w1, h1, w2, h2 = w2, h2, w1, h1
+	}
+	m.msglog.SetSize(w1, h1)
+	m.tabs[p].msglog.SetSize(w2, h2)
+
+	m.msglog.SetTitle(paneTitle(m.active, m.session, true))
+	m.tabs[p].msglog.SetTitle(paneTitle(p, m.tabs[p].session, false))
+}
+
+// paneTitle names the tab of a pane like the tab bar does.
+func paneTitle(i int, s session.Model, input bool) string {
+	title := fmt.Sprintf("%d %s", i+1, session.ShortPortName(s.GetSettings().Port))
+	if input {
+		title += " (input)"
+	}
+	return title
+}
+
+// panesView returns the message log, or both panes of the split view. A
+// pane can be selected with the mouse.
+func (m model) panesView() string {
+	p := m.partnerIndex()
+	if p < 0 {
+		return m.msglog.View()
+	}
+
+	panes := []string{
+		zone.Mark("pane-input", m.msglog.View()),
+		zone.Mark("pane-partner", m.tabs[p].msglog.View()),
+	}
+	if m.active > p {
+		panes[0], panes[1] = panes[1], panes[0]
+	}
+	if m.split.stacked {
+		return lipgloss.JoinVertical(lipgloss.Left, panes...)
+	}
+	return lipgloss.JoinHorizontal(lipgloss.Top, panes...)
+}
+
+// handlePaneMouse scrolls the other pane with the mouse wheel and moves the
+// input to it on a click. It reports whether the message was handled.
+func (m *model) handlePaneMouse(msg tea.MouseMsg) bool {
+	p := m.partnerIndex()
+	if p < 0 || !zone.Get("pane-partner").InBounds(msg) {
+		return false
+	}
+	if msg.Action == tea.MouseActionRelease {
+		m.showTab(p)
+		return true
+	}
+	m.tabs[p].msglog, _ = m.tabs[p].msglog.Update(msg)
+	return true
+}
@@ -22,6 +22,7 @@ var (
 
 	ConnectSymbolStyle      = lipgloss.NewStyle().Foreground(AdaptiveGreen)
 	DisconnectedSymbolStyle = lipgloss.NewStyle().Foreground(AdaptiveRed)
+	RecordStyle             = lipgloss.NewStyle().Foreground(AdaptiveRed).Bold(true)
 	FocusedPlaceholderStyle = lipgloss.NewStyle().Foreground(AdaptiveGray)
 	BorderStyle             = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).BorderForeground(AdaptiveBorderColor)
 	SelectedCmdStyle        = lipgloss.NewStyle().Foreground(AdaptivePink).Background(AdaptiveSelectedBg)
@@ -0,0 +1,339 @@
+package internal
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"reflect"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/cmdhist"
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/script"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/styles"
+	"github.com/mahlburgc/teaterm/internal/triggers"
+)
+
+// Every tab runs its own session with its own message log, filter and
+// command history. The components of the shown tab are the fields of the
+// model, the components of hidden tabs are kept in the tab list and get the
+// messages of their session only. Input, footer, dialogs and macros are
+// shared by all tabs and act on the shown tab.
+//
+// The commands of a tab's components are wrapped, so their messages find
+// their way back to the tab, even if another tab is shown meanwhile.
+
+// id of the first tab, the session given on the command line. It is mirrored
+// and runs the script. Share clients follow the shown tab.
+const firstTab = 0
+
+// tab holds the components of a session. Fields of the shown tab are stale,
+// its components are the fields of the model.
+type tab struct {
+	id       int
+	session  session.Model
+	msglog   msglog.Model
+	cmdhist  cmdhist.Model
+	script   script.Model
+	triggers triggers.Model
+	history  cmdHistoryFiles
+	logFile  *logrotate.Writer
+}
+
+// tabMsg is a message of the components of the tab with the id.
+type tabMsg struct {
+	id  int
+	msg tea.Msg
+}
+
+// tabConfig is what is needed to open the session of a new tab.
+type tabConfig struct {
+	newMsglog   func(serialLog *log.Logger, port string) msglog.Model
+	logDir      string // directory of the serial logs and recordings
+	logTabs     bool   // new tabs are logged
+	logRotation logrotate.Config
+	logFormat   msglog.LogFormat
+	textOutput  io.Writer // gets the messages of the shown tab as text, like share clients, nil if none
+	triggers    []triggers.Trigger
+	globalHist  bool
+}
+
+// messages of the bubbletea package, like tea.QuitMsg, are handled by the
+// program and must not be wrapped
+var teaPkgPath = reflect.TypeOf(tea.QuitMsg{}).PkgPath()
+
+// wrapCmd wraps the messages returned by the command into tab messages of
+// the tab with the id.
+func wrapCmd(id int, cmd tea.Cmd) tea.Cmd {
+	if cmd == nil {
+		return nil
+	}
+	return func() tea.Msg {
+		msg := cmd()
+		switch msg := msg.(type) {
+		case nil:
+			return nil
+		case tea.BatchMsg:
+			cmds := make([]tea.Cmd, len(msg))
+			for i, c := range msg {
+				cmds[i] = wrapCmd(id, c)
+			}
+			return tea.BatchMsg(cmds)
+		}
+		if reflect.TypeOf(msg).PkgPath() == teaPkgPath {
+			return msg
+		}
+		return tabMsg{id: id, msg: msg}
+	}
+}
+
+// tabIndex returns the index of the tab with the id or -1, if the tab was
+// closed.
+func (m model) tabIndex(id int) int {
+	for i, t := range m.tabs {
+		if t.id == id {
+			return i
+		}
+	}
+	return -1
+}
+
+// updateTab passes a message of a hidden tab to its components.
+func (m *model) updateTab(id int, msg tea.Msg) tea.Cmd {
+	i := m.tabIndex(id)
+	if i < 0 {
+		return nil
+	}
+	t := &m.tabs[i]
+
+	var cmds []tea.Cmd
+	var cmd tea.Cmd
+
+	t.session, cmd = t.session.Update(msg)
+	cmds = append(cmds, cmd)
+
+	t.msglog, cmd = t.msglog.Update(msg)
+	cmds = append(cmds, cmd)
+
+	t.cmdhist, cmd = t.cmdhist.Update(msg)
+	cmds = append(cmds, cmd)
+
+	t.script, cmd = t.script.Update(msg)
+	cmds = append(cmds, cmd)
+
+	t.triggers, cmd = t.triggers.Update(msg)
+	cmds = append(cmds, cmd)
+
+	if msg, ok := msg.(script.DoneMsg); ok {
+		m.scriptErr = msg.Err
+		m.storeHistory()
+		return tea.Quit
+	}
+
+	return wrapCmd(id, tea.Batch(cmds...))
+}
+
+// storeTab stores the components of the shown tab in the tab list.
+func (m *model) storeTab() {
+	t := &m.tabs[m.active]
+	t.session = m.session
+	t.msglog = m.msglog
+	t.cmdhist = m.cmdhist
+	t.script = m.script
+	t.triggers = m.triggers
+	t.history = m.history
+	t.logFile = m.logFile
+}
+
+// showTab shows the tab with the index.
+func (m *model) showTab(i int) {
+	if m.split.on && m.tabs[i].id == m.split.partner {
+		// the panes keep their place, the input moves to the other pane
+		m.split.partner = m.tabs[m.active].id
+	} else if m.split.on {
+		// the shown tab leaves the split view
+		m.msglog.ForceTimestamps(false)
+		m.msglog.SetTitle("Messages")
+	}
+	m.msglog.SetTextLog(nil)
+	m.storeTab()
+	m.loadTab(i)
+	m.msglog.ForceTimestamps(m.split.on)
+}
+
+// loadTab makes the components of the tab with the index the shown ones.
+func (m *model) loadTab(i int) {
+	t := m.tabs[i]
+	m.session = t.session
+	m.msglog = t.msglog
+	m.cmdhist = t.cmdhist
+	m.script = t.script
+	m.triggers = t.triggers
+	m.history = t.history
+	m.logFile = t.logFile
+	m.active = i
+	// a macro sends to the shown tab, it does not follow to another one
+	if name := m.macros.Stop(); name != "" {
+		m.msglog, _ = m.msglog.Update(events.InfoMsg(fmt.Sprintf("Macro %q stopped, the tab was changed", name)))
+	}
+	if m.tabConfig.textOutput != nil {
+		m.msglog.SetTextLog(log.New(m.tabConfig.textOutput, "", 0))
+	}
+	m.updateLayout()
+}
+
+// openTab opens a session with the settings in a new tab and shows it.
+// The port is opened in the background.
+func (m *model) openTab(settings session.Settings) tea.Cmd {
+	id := m.nextTabID
+	m.nextTabID++
+
+	var serialLog *log.Logger
+	var logFile *logrotate.Writer
+	if m.tabConfig.logTabs {
+		var closeLog func()
+		logFile, closeLog = startTabLogger(m.tabConfig.logDir, m.tabConfig.logRotation,
+			SerialLogHeader(m.tabConfig.logFormat, settings), settings.Port)
+		serialLog = log.New(logFile, "", 0)
+		m.closeLogs = append(m.closeLogs, openLog{f: logFile, close: closeLog})
+	}
+
+	// tabs have the history of their port
+	history := m.history
+	var cmdHist []string
+	if history.file != "" {
+		history.file = getCmdHistFilePath("", settings.Port)
+		cmdHist = LoadCmdHistory(history.file)
+	}
+
+	t := tab{
+		id:       id,
+		session:  session.Connect(settings),
+		msglog:   m.tabConfig.newMsglog(serialLog, settings.Port),
+		cmdhist:  cmdhist.New(cmdHist),
+		script:   script.New(nil),
+		triggers: triggers.New(m.tabConfig.triggers),
+		history:  history,
+		logFile:  logFile,
+	}
+	if m.tabConfig.globalHist {
+		t.cmdhist.SetGlobalHistory(history.global)
+	}
+
+	m.tabs = append(m.tabs, t)
+	m.showTab(len(m.tabs) - 1)
+	return wrapCmd(id, t.session.Init())
+}
+
+// closeTab closes the session of the shown tab and shows its neighbour.
+// The last tab and the tab of a running script can not be closed.
+func (m *model) closeTab() tea.Cmd {
+	info := func(s string) tea.Cmd {
+		return func() tea.Msg {
+			return events.InfoMsg(s)
+		}
+	}
+	if len(m.tabs) == 1 {
+		return info("The last tab can not be closed, quit instead")
+	}
+	if m.script.Running() {
+		return info("The tab of the running script can not be closed")
+	}
+
+	m.history.store(m.cmdhist.GetCmdHist())
+	m.session.Close()
+	if m.split.on {
+		m.endSplit()
+	}
+
+	i := m.active
+	m.tabs = append(m.tabs[:i:i], m.tabs[i+1:]...)
+	m.loadTab(min(i, len(m.tabs)-1))
+	return nil
+}
+
+// storeHistory stores the command history of all tabs.
+func (m *model) storeHistory() {
+	m.storeTab()
+	for i, t := range m.tabs {
+		if i > 0 && t.history.globalFile != "" {
+			// the pool got the commands of the tabs stored before
+			t.history.global = LoadCmdHistory(t.history.globalFile)
+		}
+		t.history.store(t.cmdhist.GetCmdHist())
+	}
+}
+
+// scriptRunning reports whether the script runs, it may run in a hidden tab.
+func (m model) scriptRunning() bool {
+	for i, t := range m.tabs {
+		if i != m.active && t.script.Running() {
+			return true
+		}
+	}
+	return m.script.Running()
+}
+
+// tabSession returns the session of the tab with the index.
+func (m model) tabSession(i int) session.Model {
+	if i == m.active {
+		return m.session
+	}
+	return m.tabs[i].session
+}
+
+// tabBarView returns the tab bar, it is only shown with several tabs.
+// Tabs can be selected with the mouse.
+func (m model) tabBarView() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	var tabs []string
+	for i := range m.tabs {
+		s := m.tabSession(i)
+		name := fmt.Sprintf("%d %s", i+1, session.ShortPortName(s.GetSettings().Port))
+		if i == m.active {
+			name = styles.FocusedPromtStyle.Render(name)
+		} else {
+			name = styles.FooterStyle.Render(name)
+		}
+		tabs = append(tabs, zone.Mark(fmt.Sprintf("tab%d", i), " "+s.StatusSymbol()+" "+name+" "))
+	}
+	bar := strings.Join(tabs, styles.FooterStyle.Render("│"))
+	return lipgloss.NewStyle().MaxWidth(m.width).Render(bar)
+}
+
+// tabsStatusView returns the connection dots of all tabs for the footer.
+func (m model) tabsStatusView() string {
+	if len(m.tabs) < 2 {
+		return ""
+	}
+	var status string
+	for i := range m.tabs {
+		num := styles.FooterStyle.Render(fmt.Sprint(i + 1))
+		if i == m.active {
+			num = styles.FocusedPromtStyle.Render(fmt.Sprint(i + 1))
+		}
+		status += " " + num + m.tabSession(i).StatusSymbol()
+	}
+	return status + styles.FooterStyle.Render(" |")
+}
+
+// handleTabClick shows the tab clicked in the tab bar.
+func (m *model) handleTabClick(msg tea.MouseMsg) {
+	if msg.Action != tea.MouseActionRelease || len(m.tabs) < 2 {
+		return
+	}
+	for i := range m.tabs {
+		if i != m.active && zone.Get(fmt.Sprintf("tab%d", i)).InBounds(msg) {
+			m.showTab(i)
+			return
+		}
+	}
+}
@@ -0,0 +1,158 @@
+// Package triggers sends automatic responses to received data. Each trigger
+// of the config file has a regular expression, the response is sent when a
+// received line matches it, e.g. a space on "Hit any key to stop autoboot".
+package triggers
+
+import (
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/escape"
+	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/session"
+)
+
+// Trigger is an auto-response rule as defined in the config file.
+type Trigger struct {
+	Name  string `toml:"name"`
+	Match string `toml:"match"`
+	Send  string `toml:"send"`
+	Mode  string `toml:"mode"` // text (default), esc or hex
+	// fire only once, until the triggers are armed again
+	Once bool `toml:"once"`
+	// minimum time between two responses
+	Cooldown time.Duration `toml:"cooldown"`
+	// disabled triggers never fire, default is enabled
+	Enabled *bool `toml:"enabled"`
+}
+
+type rule struct {
+	Trigger
+	re   *regexp.Regexp
+	mode events.InputMode
+	// a once trigger has fired
+	spent bool
+	fired time.Time
+	// fired on unterminated data of the current line, the complete line
+	// must not fire again
+	firedPartial bool
+}
+
+type Model struct {
+	rules []rule
+	armed bool
+}
+
+// Validate checks the trigger definitions of the config file.
+func Validate(triggers []Trigger) error {
+	for i, t := range triggers {
+		name := t.Name
+		if name == "" {
+			name = fmt.Sprintf("#%d", i+1)
+		}
+		if t.Match == "" {
+			return fmt.Errorf("trigger %s has no match", name)
+		}
+		if _, err := regexp.Compile(t.Match); err != nil {
+			return fmt.Errorf("trigger %s: %v", name, err)
+		}
+		mode, err := events.ParseInputMode(t.Mode)
+		if err != nil {
+			return fmt.Errorf("trigger %s: %v", name, err)
+		}
+		if _, err := session.EncodeInput(t.Send, mode); err != nil {
+			return fmt.Errorf("trigger %s: %q: %v", name, t.Send, err)
+		}
+		if t.Cooldown < 0 {
+			return fmt.Errorf("trigger %s: invalid cooldown %s", name, t.Cooldown)
+		}
+	}
+	return nil
+}
+
+// New creates the model for the given triggers, that must be validated
+// before. The triggers are armed.
+func New(triggers []Trigger) (m Model) {
+	for _, t := range triggers {
+		if t.Enabled != nil && !*t.Enabled {
+			continue
+		}
+		if t.Name == "" {
+			t.Name = t.Match
+		}
+		mode, _ := events.ParseInputMode(t.Mode)
+		m.rules = append(m.rules, rule{Trigger: t, re: regexp.MustCompile(t.Match), mode: mode})
+	}
+	m.armed = true
+	return m
+}
+
+func (m Model) Update(msg tea.Msg) (Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if key.Matches(msg, keymap.Default.ArmTriggersKey) {
+			return m, m.toggleArmed()
+		}
+
+	case events.SerialRxMsgReceived:
+		return m, m.check(string(msg), false)
+
+	case events.SerialRxPartialMsg:
+		// prompts like "Password: " are not terminated
+		return m, m.check(string(msg), true)
+	}
+
+	return m, nil
+}
+
+func (m *Model) toggleArmed() tea.Cmd {
+	m.armed = !m.armed
+	info := "Triggers disarmed"
+	if m.armed {
+		info = "Triggers armed"
+		for i := range m.rules {
+			m.rules[i].spent = false
+		}
+	}
+	if len(m.rules) == 0 {
+		info = "No triggers defined in the config file"
+	}
+	return func() tea.Msg {
+		return events.InfoMsg(info)
+	}
+}
+
+// check fires the triggers matching the received data.
+func (m *Model) check(data string, partial bool) tea.Cmd {
+	var cmds []tea.Cmd
+
+	for i := range m.rules {
+		r := &m.rules[i]
+		alreadyFired := r.firedPartial
+		r.firedPartial = partial && r.firedPartial
+
+		if !m.armed || r.spent || alreadyFired || !r.re.MatchString(data) {
+			continue
+		}
+		now := time.Now()
+		if !r.fired.IsZero() && now.Sub(r.fired) < r.Cooldown {
+			continue
+		}
+
+		r.fired = now
+		r.spent = r.Once
+		r.firedPartial = partial
+
+		name, send, mode := r.Name, r.Send, r.mode
+		info := fmt.Sprintf("Trigger %q fired, sending \"%s\"", name, escape.Quote(send))
+		cmds = append(cmds,
+			func() tea.Msg { return events.InfoMsg(info) },
+			func() tea.Msg { return events.SendMsg{Data: send, Mode: mode, Automated: true} })
+	}
+
+	return tea.Batch(cmds...)
+}
@@ -0,0 +1,85 @@
+package triggers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/mahlburgc/teaterm/events"
+)
+
+// sent feeds the messages into the model and returns the data sent by the
+// fired triggers joined by "|".
+func sent(m *Model, msgs ...tea.Msg) string {
+	var data []string
+	for _, msg := range msgs {
+		var cmd tea.Cmd
+		*m, cmd = m.Update(msg)
+		if cmd == nil {
+			continue
+		}
+		var cmds []tea.Cmd
+		if batch, ok := cmd().(tea.BatchMsg); ok {
+			cmds = batch
+		}
+		for _, c := range cmds {
+			if send, ok := c().(events.SendMsg); ok {
+				data = append(data, send.Data)
+			}
+		}
+	}
+	return strings.Join(data, "|")
+}
+
+func TestTriggers(t *testing.T) {
+	disabled := false
+	m := New([]Trigger{
+		{Name: "autoboot", Match: "Hit any key", Send: " ", Mode: "esc", Once: true},
+		{Match: `^Password: $`, Send: "secret"},
+		{Match: "error", Send: "reset", Cooldown: 50 * time.Millisecond},
+		{Match: "login", Send: "root", Enabled: &disabled},
+	})
+
+	// the partial line fires once, not again when it is completed
+	got := sent(&m,
+		events.SerialRxPartialMsg("Hit any key to stop autoboot: 3"),
+		events.SerialRxPartialMsg("Hit any key to stop autoboot: 2"),
+		events.SerialRxMsgReceived("Hit any key to stop autoboot: 0"),
+		events.SerialRxMsgReceived("Hit any key to stop autoboot: 3"),
+		events.SerialRxPartialMsg("Password: "),
+		events.SerialRxMsgReceived("login: "),
+	)
+	if want := " |secret"; got != want {
+		t.Errorf("sent = %q, want %q", got, want)
+	}
+
+	got = sent(&m, events.SerialRxMsgReceived("error 1"), events.SerialRxMsgReceived("error 2"))
+	time.Sleep(60 * time.Millisecond)
+	got += "|" + sent(&m, events.SerialRxMsgReceived("error 3"))
+	if want := "reset|reset"; got != want {
+		t.Errorf("cooldown: sent = %q, want %q", got, want)
+	}
+
+	// disarm, arming again resets once triggers
+	altA := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("a"), Alt: true}
+	if got := sent(&m, altA, events.SerialRxMsgReceived("Hit any key")); got != "" {
+		t.Errorf("disarmed trigger sent %q", got)
+	}
+	if got := sent(&m, altA, events.SerialRxMsgReceived("Hit any key")); got != " " {
+		t.Errorf("armed trigger sent %q, want %q", got, " ")
+	}
+}
+
+func TestValidate(t *testing.T) {
+	for _, tr := range []Trigger{
+		{Send: "x"},
+		{Match: "(", Send: "x"},
+		{Match: "a", Send: "0g", Mode: "hex"},
+		{Match: "a", Cooldown: -time.Second},
+	} {
+		if err := Validate([]Trigger{tr}); err == nil {
+			t.Errorf("Validate(%+v) succeeded, want error", tr)
+		}
+	}
+}
@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"errors"
 	"io"
 	"log"
+	"os"
 
 	"github.com/charmbracelet/bubbles/key"
 	"github.com/charmbracelet/bubbles/textarea"
@@ -14,62 +16,155 @@ import (
 	help "github.com/mahlburgc/teaterm/internal/help-overlay"
 	"github.com/mahlburgc/teaterm/internal/input"
 	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/macros"
 	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/script"
 	"github.com/mahlburgc/teaterm/internal/session"
+	settings "github.com/mahlburgc/teaterm/internal/settings-overlay"
+	"github.com/mahlburgc/teaterm/internal/share"
 	"github.com/mahlburgc/teaterm/internal/styles"
+	"github.com/mahlburgc/teaterm/internal/triggers"
+	"github.com/mattn/go-isatty"
 	overlay "github.com/rmhubbert/bubbletea-overlay"
-	"go.bug.st/serial"
 )
 
 type model struct {
-	msglog     msglog.Model
-	cmdhist    cmdhist.Model
-	input      input.Model
-	footer     footer.Model
-	session    session.Model
-	help       help.Model
-	showCmdLog bool
-	showHelp   bool
-	restartApp bool
-	width      int
-	height     int
+	msglog       msglog.Model
+	cmdhist      cmdhist.Model
+	input        input.Model
+	footer       footer.Model
+	session      session.Model
+	help         help.Model
+	settings     settings.Model
+	macros       macros.Model
+	script       script.Model
+	triggers     triggers.Model
+	share        share.Model
+	replay       replayModel
+	fileView     fileViewModel // viewed file, see view.go
+	scriptErr    error         // result of the script, set when the script ended
+	showCmdLog   bool
+	showMacros   bool
+	showHelp     bool
+	showSettings bool
+	restartApp   bool
+	width        int
+	height       int
+	history      cmdHistoryFiles   // command history is stored here on quit
+	logFile      *logrotate.Writer // serial log of the shown tab, nil without logging
+	tabs         []tab             // sessions of all tabs, see tabs.go
+	active       int               // index of the shown tab
+	nextTabID    int
+	tabConfig    tabConfig
+	closeLogs    []openLog // serial logs of the tabs, closed on exit
+	split        splitView
 }
 
 func initialModel(port *io.ReadWriteCloser, showTimestamp bool, cmdHist []string,
-	selectedPort string, selectedMode *serial.Mode, serialLog *log.Logger, showEscapes bool,
+	sessionSettings session.Settings, serialLog *log.Logger, showEscapes bool, hexView bool, logFormat msglog.LogFormat,
 ) model {
+	// tabs opened later get a message log with the same options
+	newMsglog := func(serialLog *log.Logger, port string) msglog.Model {
+		m := msglog.New(showTimestamp, showEscapes, hexView, styles.VpTxMsgStyle,
+			styles.ErrMsgStyle, styles.InfoMsgStyle, serialLog, logFormat, 50000)
+		m.SetPort(port)
+		return m
+	}
 	input := input.New()
 	cmdhist := cmdhist.New(cmdHist)
-	msglog := msglog.New(showTimestamp, showEscapes, styles.VpTxMsgStyle,
-		styles.ErrMsgStyle, styles.InfoMsgStyle, serialLog, 50000)
+	msglog := newMsglog(serialLog, sessionSettings.Port)
 	footer := footer.New(Version)
-	session := session.New(port, selectedPort, selectedMode)
+	session := session.New(port, sessionSettings)
 	help := help.New()
+	settingsDialog := settings.New()
 
 	return model{
-		msglog:     msglog,
-		cmdhist:    cmdhist,
-		input:      input,
-		footer:     footer,
-		session:    session,
-		help:       help,
-		showCmdLog: false,
-		showHelp:   false,
-		width:      0,
-		height:     0,
-		restartApp: false,
+		msglog:       msglog,
+		cmdhist:      cmdhist,
+		input:        input,
+		footer:       footer,
+		session:      session,
+		help:         help,
+		settings:     settingsDialog,
+		macros:       macros.New(nil),
+		script:       script.New(nil),
+		triggers:     triggers.New(nil),
+		share:        share.New(nil),
+		showCmdLog:   false,
+		showHelp:     false,
+		showSettings: false,
+		width:        0,
+		height:       0,
+		restartApp:   false,
+		tabs:         []tab{{id: firstTab}},
+		nextTabID:    firstTab + 1,
+		tabConfig:    tabConfig{newMsglog: newMsglog},
 	}
 }
 
+// Init starts the sessions of all tabs. It runs again when the program is
+// restarted after the editor, while any tab may be shown.
 func (m model) Init() tea.Cmd {
-	return tea.Batch(textarea.Blink, m.session.Init())
+	id := m.tabs[m.active].id
+	cmds := []tea.Cmd{textarea.Blink, wrapCmd(id, m.session.Init()), wrapCmd(id, m.msglog.Init()),
+		m.share.Init(), m.replay.Init(), wrapCmd(id, m.startScript)}
+	for i, t := range m.tabs {
+		if i != m.active {
+			cmds = append(cmds, wrapCmd(t.id, t.session.Init()))
+		}
+	}
+	return tea.Batch(cmds...)
 }
 
+// startScript starts the script, if any, once the program runs. The script
+// can not be started in Init, as Init can not change the model.
+func (m model) startScript() tea.Msg {
+	return startScriptMsg{}
+}
+
+type startScriptMsg struct{}
+
 func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	var cmds []tea.Cmd
+	// Recorded messages of a replay go to the first tab, it is the replayed
+	// session.
+	if rec, ok := msg.(replayMsg); ok {
+		return m.updateReplay(rec)
+	}
+
+	if tabMsg, ok := msg.(tabMsg); ok {
+		if tabMsg.id != m.tabs[m.active].id {
+			DbgLogMsgType(tabMsg.msg)
+			cmd := m.updateTab(tabMsg.id, tabMsg.msg)
+			return m, cmd
+		}
+		msg = tabMsg.msg
+	}
+	return m.update(msg)
+}
+
+// update handles the messages of the shown tab and all other messages.
+// The commands of the tab components are wrapped for the shown tab.
+func (m model) update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	var cmds, tabCmds []tea.Cmd
 	var cmd tea.Cmd
 
 	DbgLogMsgType(msg)
+	id := m.tabs[m.active].id
+
+	// While the settings dialog is open, it owns the keyboard. No other
+	// component must react to the keys typed into the dialog.
+	if m.showSettings {
+		if keyMsg, ok := msg.(tea.KeyMsg); ok {
+			if key.Matches(keyMsg, keymap.Default.CloseKey) {
+				m.showSettings = false
+				m.split.pending = false
+				return m, nil
+			}
+			m.settings, cmd = m.settings.Update(msg)
+			return m, cmd
+		}
+	}
 
 	// When the cmd-history popup is open, Enter "selects" the highlighted
 	// command into the input and dismisses the popup. We must intercept it
@@ -90,21 +185,68 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		}
 	}
 
+	// The mouse over the other pane of the split view acts on its tab only.
+	if mouseMsg, ok := msg.(tea.MouseMsg); ok && m.handlePaneMouse(mouseMsg) {
+		return m, nil
+	}
+
+	// Replay keys control the player, the port of a replay can not be
+	// changed. Other tabs have their own port.
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.tabs[m.active].id == firstTab && m.replay.Matches(keyMsg) {
+		m.replay, cmd = m.replay.Update(msg)
+		return m, cmd
+	}
+
+	// The file view has no port to change.
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.fileView.Matches(keyMsg) {
+		return m, nil
+	}
+
+	// Macro keys are not passed to the other components, they may be
+	// bound to keys that would be typed into the input otherwise.
+	if keyMsg, ok := msg.(tea.KeyMsg); ok && m.macros.Matches(keyMsg) {
+		m.macros, cmd = m.macros.Update(msg)
+		return m, cmd
+	}
+
 	m.cmdhist, cmd = m.cmdhist.Update(msg)
+	tabCmds = append(tabCmds, cmd)
+
+	m.macros, cmd = m.macros.Update(msg)
 	cmds = append(cmds, cmd)
 
-	m.msglog, cmd = m.msglog.Update(msg)
+	m.script, cmd = m.script.Update(msg)
+	tabCmds = append(tabCmds, cmd)
+
+	m.triggers, cmd = m.triggers.Update(msg)
+	tabCmds = append(tabCmds, cmd)
+
+	m.share, cmd = m.share.Update(msg)
 	cmds = append(cmds, cmd)
 
+	// keys reach the replay above, if its tab is shown
+	if _, ok := msg.(tea.KeyMsg); !ok {
+		m.replay, cmd = m.replay.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
+	m.msglog, cmd = m.msglog.Update(msg)
+	tabCmds = append(tabCmds, cmd)
+
 	m.input, cmd = m.input.Update(msg)
 	cmds = append(cmds, cmd)
 
 	m.session, cmd = m.session.Update(msg)
-	cmds = append(cmds, cmd)
+	tabCmds = append(tabCmds, cmd)
 
 	m.help, cmd = m.help.Update(msg)
 	cmds = append(cmds, cmd)
 
+	if m.showSettings {
+		m.settings, cmd = m.settings.Update(msg)
+		cmds = append(cmds, cmd)
+	}
+
 	switch msg := msg.(type) {
 	case tea.WindowSizeMsg:
 		m.width = msg.Width
@@ -114,6 +256,35 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 	case tea.KeyMsg:
 		cmds = append(cmds, m.handleKeys(msg))
 
+	case tea.MouseMsg:
+		m.handleTabClick(msg)
+
+	case session.ChangeSettingsMsg:
+		// the session keeps its settings, if they could not be applied
+		m.showSettings = false
+		m.msglog.SetPort(m.session.GetSettings().Port)
+		if m.logFile != nil {
+			// new segments carry the new settings
+			m.logFile.SetHeader(SerialLogHeader(m.tabConfig.logFormat, m.session.GetSettings()))
+		}
+
+	case settings.NewTabMsg:
+		m.showSettings = false
+		prev := m.active
+		cmds = append(cmds, m.openTab(msg.Settings))
+		if m.split.pending {
+			m.split.pending = false
+			m.startSplit(prev)
+		}
+
+	case startScriptMsg:
+		tabCmds = append(tabCmds, m.script.Start())
+
+	case script.DoneMsg:
+		m.scriptErr = msg.Err
+		m.storeHistory()
+		return m, tea.Quit
+
 	case msglog.EditorFinishedMsg:
 		// workaround bubbletea v1 bug: after executing external command,
 		// mouse support is not restored correctly. Therefore we restart bubbletea.
@@ -121,14 +292,17 @@ func (m model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 		return m, tea.Quit
 	}
 
+	for _, cmd := range tabCmds {
+		cmds = append(cmds, wrapCmd(id, cmd))
+	}
 	return m, tea.Batch(cmds...)
 }
 
 func (m model) View() string {
-	screen := lipgloss.JoinVertical(
-		lipgloss.Left,
-		m.msglog.View(),
-	)
+	screen := m.panesView()
+	if tabBar := m.tabBarView(); tabBar != "" {
+		screen = lipgloss.JoinVertical(lipgloss.Left, tabBar, screen)
+	}
 	if m.showCmdLog {
 		screen = lipgloss.JoinVertical(
 			lipgloss.Left,
@@ -136,11 +310,22 @@ func (m model) View() string {
 			m.cmdhist.View(),
 		)
 	}
+	if m.showMacros {
+		screen = lipgloss.JoinVertical(
+			lipgloss.Left,
+			screen,
+			m.macros.View(),
+		)
+	}
+	status := m.session.View()
+	if m.fileView.name != "" {
+		status = m.fileView.View()
+	}
 	screen = lipgloss.JoinVertical(
 		lipgloss.Left,
 		screen,
 		m.input.View(),
-		m.footer.View(m.session.View()),
+		m.footer.View(m.tabsStatusView()+status+m.logFileView()+m.share.View()+m.replay.View()),
 	)
 
 	output := lipgloss.Place(
@@ -155,22 +340,70 @@ func (m model) View() string {
 		output = overlay.Composite(m.help.View(), output, overlay.Center, overlay.Center, 0, 0)
 	}
 
+	if m.showSettings {
+		output = overlay.Composite(m.settings.View(), output, overlay.Center, overlay.Center, 0, 0)
+	}
+
 	return zone.Scan(output)
 }
 
 func (m *model) handleKeys(keyMsg tea.KeyMsg) tea.Cmd {
 	switch {
 	case key.Matches(keyMsg, keymap.Default.QuitKey):
-		StoreConfig(m.cmdhist.GetCmdHist())
+		m.storeHistory()
+		if m.scriptRunning() {
+			m.scriptErr = errors.New("script aborted")
+		}
 		return tea.Quit
 
 	case key.Matches(keyMsg, keymap.Default.ToggleHistKey):
 		m.showCmdLog = !m.showCmdLog
 		m.updateLayout()
 
+	case key.Matches(keyMsg, keymap.Default.FavoritesKey):
+		m.showMacros = !m.showMacros
+		m.updateLayout()
+
 	case key.Matches(keyMsg, keymap.Default.HelpKey):
 		m.showHelp = !m.showHelp
 
+	case key.Matches(keyMsg, keymap.Default.SettingsKey):
+		m.showHelp = false
+		m.showSettings = true
+		return m.settings.Open(m.session.GetSettings())
+
+	case key.Matches(keyMsg, keymap.Default.NewTabKey):
+		m.showHelp = false
+		m.showSettings = true
+		return m.settings.OpenNewTab(m.session.GetSettings())
+
+	case key.Matches(keyMsg, keymap.Default.CloseTabKey):
+		return m.closeTab()
+
+	case key.Matches(keyMsg, keymap.Default.NextTabKey):
+		m.showTab((m.active + 1) % len(m.tabs))
+
+	case key.Matches(keyMsg, keymap.Default.PrevTabKey):
+		m.showTab((m.active + len(m.tabs) - 1) % len(m.tabs))
+
+	case key.Matches(keyMsg, keymap.Default.SplitKey):
+		return m.toggleSplit()
+
+	case key.Matches(keyMsg, keymap.Default.RecordKey, keymap.Default.RecordAllKey):
+		return m.toggleRecording(key.Matches(keyMsg, keymap.Default.RecordAllKey))
+
+	case key.Matches(keyMsg, keymap.Default.SwitchPaneKey):
+		if p := m.partnerIndex(); p >= 0 {
+			m.showTab(p)
+		}
+
+	case key.Matches(keyMsg, keymap.Default.SplitLayoutKey):
+		m.split.stacked = !m.split.stacked
+		m.updateLayout()
+
+	case key.Matches(keyMsg, keymap.Default.GrowPaneKey, keymap.Default.ShrinkPaneKey):
+		m.resizeSplit(key.Matches(keyMsg, keymap.Default.GrowPaneKey))
+
 	case key.Matches(keyMsg, keymap.Default.CloseKey, keymap.Default.ResetKey):
 		m.showHelp = false
 		m.showCmdLog = false
@@ -181,8 +414,17 @@ func (m *model) handleKeys(keyMsg tea.KeyMsg) tea.Cmd {
 }
 
 func (m *model) updateLayout() {
+	m.macros.SetWidth(m.width)
+	m.macros.SetVisible(m.showMacros)
+
 	footerHeight := m.footer.GetHeight()
+	if len(m.tabs) > 1 {
+		footerHeight++ // tab bar
+	}
 	inputHeight := m.input.GetHeight()
+	if m.showMacros {
+		inputHeight += m.macros.GetHeight()
+	}
 
 	cmdLogHeight := 10
 	maxCmdLogHeight := m.height / 3
@@ -202,23 +444,91 @@ func (m *model) updateLayout() {
 
 	m.footer.SetWidth(m.width)
 	m.input.SetWidth(m.width)
-	m.msglog.SetSize(m.width, msgLogHeight)
+	m.layoutPanes(msgLogHeight)
 	// Keep cmdhist's popup state in sync before SetSize: SetSize calls
 	// ResetVp, whose resting selection depends on whether the popup is open.
 	m.cmdhist.SetPopupOpen(m.showCmdLog)
 	m.cmdhist.SetSize(m.width, cmdLogHeight)
 }
 
-func RunTui(port *io.ReadWriteCloser, mode serial.Mode, flags Flags, config Config, serialLog *log.Logger) {
+// RunTui runs the interactive session. If a script is given, it is run in the
+// session and its result is returned. Without terminal, e.g. in CI, the script
+// runs without TUI and the conversation is printed instead.
+func RunTui(port *io.ReadWriteCloser, settings session.Settings, flags Flags, config Config,
+	logFile *logrotate.Writer, runScript *script.Script, pty *session.Pty, shareServer *share.Server,
+) error {
 	zone.NewGlobal()
 
-	m := initialModel(port, flags.Timestamp, config.CmdHistoryLines, flags.Port, &mode, serialLog, flags.ShowEscapes)
+	// share clients get the messages of the shown tab
+	var textOutputs []io.Writer
+	if shareServer != nil {
+		textOutputs = append(textOutputs, shareServer)
+	}
+
+	opts := []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()}
+	if runScript != nil && !isatty.IsTerminal(os.Stdout.Fd()) {
+		opts = []tea.ProgramOption{tea.WithoutRenderer(), tea.WithInput(nil)}
+		textOutputs = append(textOutputs, os.Stdout)
+	}
+
+	m := initialModel(port, flags.Timestamp, config.CmdHistoryLines, settings, nil, flags.ShowEscapes,
+		flags.HexView, msglog.LogFormat(flags.LogFormat))
+	m.history = cmdHistoryFiles{
+		file:       flags.HistoryFile,
+		globalFile: GlobalHistoryFile(),
+		global:     config.GlobalCmdHistoryLines,
+		size:       flags.HistSize,
+	}
+	m.macros = macros.New(flags.Macros)
+	m.script = script.New(runScript)
+	m.triggers = triggers.New(flags.Triggers)
+	m.share = share.New(shareServer)
+	m.setReplay(*port)
+	if pty != nil {
+		m.session.SetMirror(pty)
+	}
+	if flags.GlobalHist {
+		m.cmdhist.SetGlobalHistory(config.GlobalCmdHistoryLines)
+	}
+	m.tabConfig.triggers = flags.Triggers
+	m.tabConfig.globalHist = flags.GlobalHist
+	m.tabConfig.logDir = flags.Logfilepath
+	m.tabConfig.logTabs = flags.Logfile
+	m.tabConfig.logRotation, _ = flags.LogRotation() // checked by GetFlags
+	m.tabConfig.logFormat = msglog.LogFormat(flags.LogFormat)
+	if len(textOutputs) > 0 {
+		m.tabConfig.textOutput = io.MultiWriter(textOutputs...)
+		m.msglog.SetTextLog(log.New(m.tabConfig.textOutput, "", 0))
+	}
+	m.logFile = logFile
+	m.setSerialLog()
+	defer func() {
+		for _, l := range m.closeLogs {
+			l.close()
+		}
+	}()
 
+	var err error
+	m, err = runProgram(m, opts)
+	if errors.Is(err, tea.ErrInterrupted) && runScript != nil {
+		// ctrl+c of a headless script
+		return errors.New("script aborted")
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	return m.scriptErr
+}
+
+// runProgram runs the tea program with the model until it quits and returns
+// the final model. The program is restarted after the editor was closed.
+func runProgram(m model, opts []tea.ProgramOption) (model, error) {
 	for {
-		p := tea.NewProgram(m, tea.WithAltScreen(), tea.WithMouseCellMotion())
+		p := tea.NewProgram(m, opts...)
 		finalModel, err := p.Run()
 		if err != nil {
-			log.Fatal(err)
+			return m, err
 		}
 
 		var ok bool
@@ -228,7 +538,7 @@ func RunTui(port *io.ReadWriteCloser, mode serial.Mode, flags Flags, config Conf
 		}
 
 		if !m.restartApp {
-			break
+			return m, nil
 		}
 		m.restartApp = false
 	}
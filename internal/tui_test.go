@@ -1,21 +1,33 @@
 package internal
 
 import (
+	"bytes"
 	"io"
+	"log"
+	"os"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/charmbracelet/bubbles/cursor"
 	tea "github.com/charmbracelet/bubbletea"
 	zone "github.com/lrstanley/bubblezone"
 	"github.com/mahlburgc/teaterm/events"
+	"github.com/mahlburgc/teaterm/internal/macros"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/session"
+	settings "github.com/mahlburgc/teaterm/internal/settings-overlay"
+	"go.bug.st/serial"
 )
 
+// mockSettings are the session settings used together with the mocked port.
+var mockSettings = session.Settings{Port: "mock", Mode: serial.Mode{BaudRate: 115200}, LineEnding: "\r\n"}
+
 // processCmd executes a tea.Cmd and feeds resulting messages back into the
 // model, simulating the bubbletea event loop. Emitted HistCmdSelected
 // messages are recorded in selectedLog before being dispatched.
 func processCmd(m model, cmd tea.Cmd, selectedLog *[]string, depth int) model {
-	if cmd == nil || depth > 8 {
+	if cmd == nil || depth > 16 {
 		return m
 	}
 	return processMsg(m, cmd(), selectedLog, depth)
@@ -36,8 +48,13 @@ func processMsg(m model, msg tea.Msg, selectedLog *[]string, depth int) model {
 	if _, ok := msg.(cursor.BlinkMsg); ok {
 		return m
 	}
-	if selected, ok := msg.(events.HistCmdSelected); ok && selectedLog != nil {
-		*selectedLog = append(*selectedLog, string(selected))
+	// messages of the tab components are wrapped for their tab
+	inner := msg
+	if tabMsg, ok := msg.(tabMsg); ok {
+		inner = tabMsg.msg
+	}
+	if selected, ok := inner.(events.HistCmdSelected); ok && selectedLog != nil {
+		*selectedLog = append(*selectedLog, selected.Data)
 	}
 	nm, cmd := m.Update(msg)
 	m = nm.(model)
@@ -51,10 +68,9 @@ func processMsg(m model, msg tea.Msg, selectedLog *[]string, depth int) model {
 // last item.
 func TestCmdHistEnterSelectsHighlighted(t *testing.T) {
 	zone.NewGlobal()
-	var port io.ReadWriteCloser
-	port, mode := OpenFakePort()
+	port := OpenFakePort()
 	defer port.Close()
-	m := initialModel(&port, false, []string{"alpha", "bravo", "charlie"}, "mock", &mode, nil, false)
+	m := initialModel(&port, false, []string{"alpha", "bravo", "charlie"}, mockSettings, nil, false, false, msglog.LogText)
 
 	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
 
@@ -103,11 +119,10 @@ func TestCmdHistEnterSelectsHighlighted(t *testing.T) {
 // broadcast and the filter stayed on the typed prefix.
 func TestAutoCompleteUpdatesHistFilter(t *testing.T) {
 	zone.NewGlobal()
-	var port io.ReadWriteCloser
-	port, mode := OpenFakePort()
+	port := OpenFakePort()
 	defer port.Close()
 	// "a" fuzzy-matches both; the completed "ab" only matches itself.
-	m := initialModel(&port, false, []string{"axc", "ab"}, "mock", &mode, nil, false)
+	m := initialModel(&port, false, []string{"axc", "ab"}, mockSettings, nil, false, false, msglog.LogText)
 
 	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
 	m = processMsg(m, tea.KeyMsg{Type: tea.KeyCtrlR}, nil, 0)
@@ -135,10 +150,9 @@ func TestAutoCompleteUpdatesHistFilter(t *testing.T) {
 // the most recent command. Down past the newest recalls an empty input.
 func TestClosedPopupRecall(t *testing.T) {
 	zone.NewGlobal()
-	var port io.ReadWriteCloser
-	port, mode := OpenFakePort()
+	port := OpenFakePort()
 	defer port.Close()
-	m := initialModel(&port, false, []string{"alpha", "bravo", "charlie"}, "mock", &mode, nil, false)
+	m := initialModel(&port, false, []string{"alpha", "bravo", "charlie"}, mockSettings, nil, false, false, msglog.LogText)
 
 	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
 	if m.showCmdLog {
@@ -162,3 +176,517 @@ func TestClosedPopupRecall(t *testing.T) {
 		t.Errorf("recall mirrored %q into input, want %q", log, want)
 	}
 }
+
+// TestSettingsDialogChangesMode verifies that port settings applied in the
+// settings dialog reach the running session, while the command history stays
+// intact and typed keys do not leak into the input.
+func TestSettingsDialogChangesMode(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	m := initialModel(&port, false, []string{"alpha", "bravo"}, mockSettings, nil, false, false, msglog.LogText)
+
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyCtrlS}, nil, 0)
+	if !m.showSettings {
+		t.Fatal("settings dialog not open after ctrl+s")
+	}
+
+	// Select the baud rate field, replace its value and cycle the parity.
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyDown}, nil, 0)
+	for range len("115200") {
+		m = processMsg(m, tea.KeyMsg{Type: tea.KeyBackspace}, nil, 0)
+	}
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("9600")}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyDown}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyDown}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyRight}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyRight}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyEnter}, nil, 0)
+
+	if m.showSettings {
+		t.Error("settings dialog still open after enter")
+	}
+	if got := session.FormatMode(m.session.GetSettings().Mode); got != "9600 8E1" {
+		t.Errorf("session mode = %q, want %q", got, "9600 8E1")
+	}
+	if got := strings.Join(m.cmdhist.GetCmdHist(), "|"); got != "alpha|bravo" {
+		t.Errorf("cmd history = %q, want %q", got, "alpha|bravo")
+	}
+	if view := m.input.View(); strings.Contains(view, "9600") {
+		t.Error("keys typed into the settings dialog leaked into the input")
+	}
+}
+
+// TestPartialRxUpdatedInPlace verifies that an unterminated rx message is
+// shown once and replaced by the complete message, even if a tx message was
+// logged in between.
+func TestPartialRxUpdatedInPlace(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	// Feed the msglog directly, dispatching rx messages to the model would
+	// start blocking reads on the mocked port.
+	for _, msg := range []tea.Msg{
+		events.SerialRxPartialMsg("login:"),
+		events.SerialRxPartialMsg("login: "),
+		events.SerialTxMsg{Data: "root", LineEnding: "\r\n"},
+		events.SerialRxMsgReceived("login: root"),
+	} {
+		m.msglog, _ = m.msglog.Update(msg)
+	}
+
+	// start message, rx message and tx message
+	if got := m.msglog.GetLen(); got != 3 {
+		t.Errorf("log length = %d, want 3", got)
+	}
+	view := m.msglog.Vp.View()
+	if !strings.Contains(view, "login: root") {
+		t.Errorf("complete rx message not shown:\n%s", view)
+	}
+	if strings.Count(view, "login:") != 1 {
+		t.Errorf("partial rx message not replaced:\n%s", view)
+	}
+}
+
+// TestSplitRxLoggedAsOneLine verifies that the parts of a split rx line are
+// shown as separate messages, but written to the serial log as one line.
+func TestSplitRxLoggedAsOneLine(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	var serialLog bytes.Buffer
+	m := initialModel(&port, false, nil, mockSettings, log.New(&serialLog, "", 0), false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	for _, msg := range []tea.Msg{
+		events.SerialRxSplitMsg("aaaa"),
+		events.SerialRxPartialMsg("bb"),
+		events.SerialRxSplitMsg("bbbb"),
+		events.SerialRxMsgReceived("cc"),
+	} {
+		m.msglog, _ = m.msglog.Update(msg)
+	}
+
+	// start message and three parts
+	if got := m.msglog.GetLen(); got != 4 {
+		t.Errorf("log length = %d, want 4", got)
+	}
+	if got, want := serialLog.String(), "aaaabbbbcc\n"; got != want {
+		t.Errorf("serial log = %q, want %q", got, want)
+	}
+}
+
+// TestHexViewToggle verifies that alt+x renders the logged messages again as
+// hex dump and back, without leaking the key into the input.
+func TestHexViewToggle(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 120, Height: 30}, nil, 0)
+
+	m.msglog, _ = m.msglog.Update(events.SerialRxMsgReceived("hello"))
+	m.msglog, _ = m.msglog.Update(events.SerialTxMsg{Data: "0123456789abcdef", LineEnding: "\r\n"})
+
+	altX := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("x"), Alt: true}
+	m = processMsg(m, altX, nil, 0)
+	view := m.msglog.Vp.View()
+	for _, want := range []string{"RX 00000000  68 65 6c 6c 6f", "TX 00000010  0d 0a"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("hex view does not contain %q:\n%s", want, view)
+		}
+	}
+	if strings.Contains(m.input.View(), "x") {
+		t.Error("alt+x leaked into the input")
+	}
+
+	m = processMsg(m, altX, nil, 0)
+	if view := m.msglog.Vp.View(); !strings.Contains(view, "hello") || strings.Contains(view, "68 65") {
+		t.Errorf("text view not restored:\n%s", view)
+	}
+}
+
+// TestHexInputMode verifies that hex input is validated inline, only valid
+// input is sent and the command history remembers the input mode.
+func TestHexInputMode(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	m := initialModel(&port, false, []string{"alpha"}, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	altI := tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("i"), Alt: true}
+	m = processMsg(m, altI, nil, 0) // escape mode
+	m = processMsg(m, altI, nil, 0) // hex mode
+	if view := m.input.View(); !strings.Contains(view, "Hex> ") {
+		t.Fatalf("hex prompt not shown:\n%s", view)
+	}
+
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("01 0g")}, nil, 0)
+	if view := m.input.View(); !strings.Contains(view, "invalid hex byte") {
+		t.Errorf("invalid input not reported:\n%s", view)
+	}
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyEnter}, nil, 0)
+	if got := len(m.cmdhist.GetCmdHist()); got != 1 {
+		t.Errorf("invalid input was sent, cmd history length = %d", got)
+	}
+
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyBackspace}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("A FF")}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyEnter}, nil, 0)
+	if got := strings.Join(m.cmdhist.GetCmdHist(), "|"); got != "alpha|hex\t01 0A FF" {
+		t.Errorf("cmd history = %q, want %q", got, "alpha|hex\t01 0A FF")
+	}
+
+	// Recalling a text command switches back to text mode.
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyUp}, nil, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyUp}, nil, 0)
+	if view := m.input.View(); !strings.Contains(view, "> alpha") || strings.Contains(view, "Hex>") {
+		t.Errorf("text command not recalled in text mode:\n%s", view)
+	}
+}
+
+// TestGlobalCmdHistory verifies that commands of the global pool can be
+// recalled, but only sent commands are stored in the own history.
+func TestGlobalCmdHistory(t *testing.T) {
+	zone.NewGlobal()
+	port := OpenFakePort()
+	defer port.Close()
+	m := initialModel(&port, false, []string{"alpha"}, mockSettings, nil, false, false, msglog.LogText)
+	m.cmdhist.SetGlobalHistory([]string{"beta", "alpha", "gamma"})
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	var selected []string
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyUp}, &selected, 0)
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyUp}, &selected, 0)
+	if got := strings.Join(selected, "|"); got != "alpha|gamma" {
+		t.Errorf("recalled = %q, want %q", got, "alpha|gamma")
+	}
+
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyEnter}, nil, 0)
+	if got := strings.Join(m.cmdhist.GetCmdHist(), "|"); got != "alpha|gamma" {
+		t.Errorf("cmd history = %q, want %q", got, "alpha|gamma")
+	}
+}
+
+// writeRecorder is a port that records all written data and receives nothing.
+type writeRecorder struct {
+	written bytes.Buffer
+}
+
+func (w *writeRecorder) Read(p []byte) (int, error)  { return 0, io.EOF }
+func (w *writeRecorder) Write(p []byte) (int, error) { return w.written.Write(p) }
+func (w *writeRecorder) Close() error                { return nil }
+
+// TestMacro verifies that a macro key sends all commands of the macro in
+// order, without adding them to the command history or the input.
+func TestMacro(t *testing.T) {
+	zone.NewGlobal()
+	rec := &writeRecorder{}
+	var port io.ReadWriteCloser = rec
+	m := initialModel(&port, false, []string{"alpha"}, mockSettings, nil, false, false, msglog.LogText)
+	m.macros = macros.New([]macros.Macro{{
+		Name: "boot",
+		Key:  "f1",
+		Commands: []macros.Command{
+			{Send: "reset"},
+			{Send: `\x03`, Mode: "esc", Delay: 10 * time.Millisecond},
+			{Send: "boot"},
+		},
+	}})
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	m = processMsg(m, tea.KeyMsg{Type: tea.KeyF1}, nil, 0)
+	if got, want := rec.written.String(), "reset\r\n\x03boot\r\n"; got != want {
+		t.Errorf("written = %q, want %q", got, want)
+	}
+	if got := strings.Join(m.cmdhist.GetCmdHist(), "|"); got != "alpha" {
+		t.Errorf("cmd history = %q, want %q", got, "alpha")
+	}
+}
+
+// A macro stops when another tab is shown, the tab it sends to is hidden.
+func TestMacroStoppedOnTabChange(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m.macros = macros.New([]macros.Macro{{
+		Name:     "boot",
+		Key:      "f1",
+		Commands: []macros.Command{{Send: "reset", Delay: time.Hour}},
+	}})
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+	m = processMsg(m, events.ConnectionStatusMsg{Status: events.Connected}, nil, 0)
+
+	update := func(msg tea.Msg) {
+		t.Helper()
+		nm, _ := m.Update(msg)
+		m = nm.(model)
+	}
+	update(tea.KeyMsg{Type: tea.KeyF1})
+	second := mockSettings
+	second.Port = "second"
+	update(settings.NewTabMsg{Settings: second})
+
+	if !strings.Contains(m.msglog.View(), `Macro "boot" stopped`) {
+		t.Error("macro not stopped after the tab was changed")
+	}
+	if m.macros.Stop() != "" {
+		t.Error("macro still running")
+	}
+}
+
+func TestMacroValidate(t *testing.T) {
+	tests := []struct {
+		macro macros.Macro
+		err   string
+	}{
+		{macros.Macro{Name: "a", Key: "ctrl+q", Commands: []macros.Command{{Send: "x"}}}, "already used to quit"},
+		{macros.Macro{Name: "a", Key: "f1"}, "no commands"},
+		{macros.Macro{Name: "a", Commands: []macros.Command{{Send: "0g", Mode: "hex"}}}, "invalid hex byte"},
+		{macros.Macro{Name: "a", Commands: []macros.Command{{Send: "x", Mode: "raw"}}}, "invalid mode"},
+	}
+	for _, tt := range tests {
+		err := macros.Validate([]macros.Macro{tt.macro})
+		if err == nil || !strings.Contains(err.Error(), tt.err) {
+			t.Errorf("Validate(%+v) = %v, want error containing %q", tt.macro, err, tt.err)
+		}
+	}
+}
+
+// TestTabs verifies that the messages of a hidden tab reach its own log only
+// and that tabs can be switched and closed.
+func TestTabs(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	update := func(msg tea.Msg) {
+		t.Helper()
+		nm, _ := m.Update(msg)
+		m = nm.(model)
+	}
+
+	second := mockSettings
+	second.Port = "second"
+	update(settings.NewTabMsg{Settings: second})
+	if len(m.tabs) != 2 || m.session.GetSettings().Port != "second" {
+		t.Fatalf("new tab not shown, %d tabs", len(m.tabs))
+	}
+
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("hello")})
+	if strings.Contains(m.msglog.View(), "hello") {
+		t.Error("message of the hidden tab shown in the new tab")
+	}
+
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("n"), Alt: true})
+	if m.session.GetSettings().Port != "mock" || !strings.Contains(m.msglog.View(), "hello") {
+		t.Error("first tab not shown with its message after alt+n")
+	}
+	if !strings.Contains(m.View(), "2 second") {
+		t.Error("tab bar not shown")
+	}
+
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("w"), Alt: true})
+	if len(m.tabs) != 1 || m.session.GetSettings().Port != "second" {
+		t.Errorf("first tab not closed, %d tabs", len(m.tabs))
+	}
+}
+
+// TestSplitView verifies that the split view shows both tabs with
+// timestamps and that the input can be moved to the other pane.
+func TestSplitView(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 100, Height: 30}, nil, 0)
+
+	update := func(msg tea.Msg) {
+		t.Helper()
+		nm, _ := m.Update(msg)
+		m = nm.(model)
+	}
+	alt := func(r rune) tea.KeyMsg {
+		return tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune{r}, Alt: true}
+	}
+
+	second := mockSettings
+	second.Port = "second"
+	update(alt('s'))
+	update(settings.NewTabMsg{Settings: second})
+	if !m.split.on || m.partnerIndex() != 0 {
+		t.Fatal("view not split with the new tab")
+	}
+
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("hello")})
+	view := m.View()
+	for _, want := range []string{"2 second (input)", "1 mock", "] hello"} {
+		if !strings.Contains(view, want) {
+			t.Errorf("split view does not contain %q", want)
+		}
+	}
+
+	update(alt('o'))
+	if m.session.GetSettings().Port != "mock" || !strings.Contains(m.View(), "1 mock (input)") {
+		t.Error("input not moved to the other pane")
+	}
+
+	update(alt('s'))
+	if m.split.on || strings.Contains(m.msglog.View(), "] hello") {
+		t.Error("split view not ended")
+	}
+}
+
+// TestRecording verifies that a recording started during the session gets
+// the scrollback and the start and stop markers.
+func TestRecording(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m.tabConfig.logDir = t.TempDir()
+	m = processMsg(m, tea.WindowSizeMsg{Width: 100, Height: 30}, nil, 0)
+
+	update := func(msg tea.Msg) {
+		t.Helper()
+		nm, _ := m.Update(msg)
+		m = nm.(model)
+	}
+
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("before")})
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("R"), Alt: true})
+	if m.logFile == nil || !strings.Contains(m.View(), "● REC") {
+		t.Fatal("recording not started")
+	}
+	path := m.logFile.Name()
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("during")})
+	update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r"), Alt: true})
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("after")})
+	if m.logFile != nil || strings.Contains(m.View(), "● REC") {
+		t.Error("recording not stopped")
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	want := []string{"INFO: teaterm", "before", "INFO: Recording started", "during", "INFO: Recording stopped"}
+	if len(lines) != len(want) {
+		t.Fatalf("recording = %q, want %d lines", lines, len(want))
+	}
+	for i, prefix := range want {
+		if !strings.HasPrefix(lines[i], prefix) {
+			t.Errorf("line %d = %q, want prefix %q", i, lines[i], prefix)
+		}
+	}
+}
+
+// TestRecordingTwice verifies that a recording started within the same
+// second goes into a new file and stopped recordings are not kept.
+func TestRecordingTwice(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m.tabConfig.logDir = t.TempDir()
+	m = processMsg(m, tea.WindowSizeMsg{Width: 100, Height: 30}, nil, 0)
+
+	record := func() string {
+		t.Helper()
+		nm, _ := m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("R"), Alt: true})
+		m = nm.(model)
+		if m.logFile == nil {
+			t.Fatal("recording not started")
+		}
+		path := m.logFile.Name()
+		nm, _ = m.Update(tea.KeyMsg{Type: tea.KeyRunes, Runes: []rune("r"), Alt: true})
+		m = nm.(model)
+		return path
+	}
+
+	first, second := record(), record()
+	if first == second {
+		t.Fatalf("both recordings went into %s", first)
+	}
+	if len(m.closeLogs) != 0 {
+		t.Errorf("%d stopped recordings kept open until exit", len(m.closeLogs))
+	}
+	data, err := os.ReadFile(first)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if n := strings.Count(string(data), "Recording started"); n != 1 {
+		t.Errorf("first recording has %d starts, want 1", n)
+	}
+}
+
+// TestShareFollowsShownTab verifies that share clients get the messages of
+// the shown tab and their lines are sent to its port.
+func TestShareFollowsShownTab(t *testing.T) {
+	zone.NewGlobal()
+	rec := &writeRecorder{}
+	var port io.ReadWriteCloser = rec
+	var out bytes.Buffer
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogJSONL)
+	m.tabConfig.textOutput = &out
+	m.msglog.SetTextLog(log.New(&out, "", 0))
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+
+	update := func(msg tea.Msg) {
+		t.Helper()
+		nm, _ := m.Update(msg)
+		m = nm.(model)
+	}
+
+	second := mockSettings
+	second.Port = "second"
+	update(settings.NewTabMsg{Settings: second})
+	update(tabMsg{id: firstTab, msg: events.SerialRxMsgReceived("hidden")})
+	update(tabMsg{id: firstTab + 1, msg: events.SerialRxMsgReceived("shown")})
+	if got, want := out.String(), "shown\n"; got != want {
+		t.Errorf("share output = %q, want %q", got, want)
+	}
+
+	// the port of the new tab is not connected yet
+	m = processMsg(m, events.ForwardMsg{Data: "reboot", Source: "client"}, nil, 0)
+	if rec.written.Len() > 0 {
+		t.Errorf("line of the client sent to the first tab: %q", rec.written.String())
+	}
+	if !strings.Contains(m.msglog.View(), "data of client dropped") {
+		t.Error("line of the client not passed to the shown tab")
+	}
+}
+
+// TestInitShownTab verifies that a restarted program, e.g. after the editor,
+// starts the shown tab's components for that tab.
+func TestInitShownTab(t *testing.T) {
+	zone.NewGlobal()
+	var port io.ReadWriteCloser = &writeRecorder{}
+	m := initialModel(&port, false, nil, mockSettings, nil, false, false, msglog.LogText)
+	m = processMsg(m, tea.WindowSizeMsg{Width: 80, Height: 30}, nil, 0)
+	nm, _ := m.Update(settings.NewTabMsg{Settings: mockSettings})
+	m = nm.(model)
+
+	batch, ok := m.Init()().(tea.BatchMsg)
+	if !ok {
+		t.Fatal("Init returned no batch")
+	}
+	var started bool
+	for _, cmd := range batch {
+		if cmd == nil {
+			continue
+		}
+		if msg, ok := cmd().(tabMsg); ok {
+			if _, ok := msg.msg.(startScriptMsg); ok {
+				started = msg.id == m.tabs[m.active].id
+			}
+		}
+	}
+	if !started {
+		t.Error("script start not sent to the shown tab")
+	}
+}
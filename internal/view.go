@@ -0,0 +1,109 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/charmbracelet/bubbles/key"
+	tea "github.com/charmbracelet/bubbletea"
+	zone "github.com/lrstanley/bubblezone"
+	"github.com/mahlburgc/teaterm/internal/keymap"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/session"
+	"github.com/mahlburgc/teaterm/internal/styles"
+)
+
+// The view command shows a file in the message log, read-only. The session
+// runs on a port that never receives anything, the input only filters and
+// the footer shows the file instead of the port.
+
+// fileViewModel shows the viewed file in the footer, if the TUI views a
+// file.
+type fileViewModel struct {
+	name string
+	size int64
+}
+
+// Matches reports whether the key is blocked in the file view. There is no
+// port to change and no session to open.
+func (m fileViewModel) Matches(msg tea.KeyMsg) bool {
+	return m.name != "" && key.Matches(msg, keymap.Default.ToggleSessionKey, keymap.Default.SettingsKey,
+		keymap.Default.NewTabKey, keymap.Default.SplitKey, keymap.Default.RecordKey, keymap.Default.RecordAllKey)
+}
+
+// View returns the file name and size for the footer.
+func (m fileViewModel) View() string {
+	return styles.FooterStyle.Render(fmt.Sprintf(" %s %s", m.name, formatSize(m.size)))
+}
+
+// formatSize formats a file size in bytes with a binary unit.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+	div, exp := int64(unit), 0
+	for n := size / unit; n >= unit; n /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(size)/float64(div), "KMGTPE"[exp])
+}
+
+// viewPort is the port of the file view session. Nothing is received and
+// nothing can be sent.
+type viewPort struct {
+	done chan struct{}
+	once sync.Once
+}
+
+func newViewPort() *viewPort {
+	return &viewPort{done: make(chan struct{})}
+}
+
+// Read blocks until the port is closed.
+func (p *viewPort) Read([]byte) (int, error) {
+	<-p.done
+	return 0, io.EOF
+}
+
+func (p *viewPort) Write([]byte) (int, error) {
+	return 0, errors.New("sending is not possible in the file view")
+}
+
+func (p *viewPort) Close() error {
+	p.once.Do(func() { close(p.done) })
+	return nil
+}
+
+// RunView shows the file of the view command in the TUI.
+func RunView(flags Flags) error {
+	info, err := os.Stat(flags.View)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		return fmt.Errorf("%s is a directory", flags.View)
+	}
+
+	zone.NewGlobal()
+
+	port := io.ReadWriteCloser(newViewPort())
+	defer port.Close()
+
+	m := initialModel(&port, false, nil, session.Settings{Port: flags.View}, nil, flags.ShowEscapes, false,
+		msglog.LogFormat(flags.LogFormat))
+	if err := m.msglog.ShowFile(flags.View); err != nil {
+		return err
+	}
+	defer m.msglog.CloseFile()
+	m.input.SetFilterOnly()
+	m.fileView = fileViewModel{name: filepath.Base(flags.View), size: info.Size()}
+
+	_, err = runProgram(m, []tea.ProgramOption{tea.WithAltScreen(), tea.WithMouseCellMotion()})
+	return err
+}
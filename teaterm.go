@@ -1,22 +1,71 @@
 package main
 
 import (
+	"fmt"
 	"io"
 	"log"
 	"os"
 
 	"github.com/mahlburgc/teaterm/internal"
+	"github.com/mahlburgc/teaterm/internal/logrotate"
+	"github.com/mahlburgc/teaterm/internal/msglog"
+	"github.com/mahlburgc/teaterm/internal/script"
 	"github.com/mahlburgc/teaterm/internal/session"
-	"go.bug.st/serial"
+	"github.com/mahlburgc/teaterm/internal/share"
 )
 
 func main() {
+	os.Exit(run())
+}
+
+// run runs teaterm and returns the exit status.
+func run() int {
 	config := internal.GetConfig()
-	flags := internal.GetFlags()
+	flags, err := internal.GetFlags(config)
+	if err != nil {
+		fmt.Println(err)
+		return 1
+	}
 
 	if flags.List {
 		session.ListPorts()
-		return
+		return 0
+	}
+
+	var runScript *script.Script
+	if flags.Script != "" {
+		runScript, err = script.Load(flags.Script)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	}
+
+	settings, err := flags.SessionSettings()
+	if err != nil {
+		fmt.Printf("%s: %s\n", flags.Port, err.Error())
+		return 1
+	}
+
+	if err := flags.CheckLogFormat(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if err := flags.CheckMacros(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	if err := flags.CheckTriggers(); err != nil {
+		fmt.Println(err)
+		return 1
+	}
+
+	shareListeners, err := flags.ShareListeners()
+	if err != nil {
+		fmt.Println(err)
+		return 1
 	}
 
 	if len(os.Getenv("TEATERM_DBG_LOG")) > 0 {
@@ -27,12 +76,26 @@ func main() {
 		log.SetOutput(io.Discard)
 	}
 
+	if flags.View != "" {
+		if err := internal.RunView(flags); err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		return 0
+	}
+
 	var initialPort io.ReadWriteCloser
-	var mode serial.Mode
-	if len(os.Getenv("TEATERM_MOCK_PORT")) > 0 {
-		initialPort, mode = internal.OpenFakePort()
-	} else {
-		initialPort, mode = session.OpenPort(flags.Port)
+	switch {
+	case flags.Replay != "":
+		initialPort, err = internal.OpenReplayPort(flags.Replay, flags.Speed, flags.Step)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+	case len(os.Getenv("TEATERM_MOCK_PORT")) > 0:
+		initialPort = internal.OpenFakePort()
+	default:
+		initialPort = session.OpenPort(settings)
 	}
 
 	// During program execution it might happen that the serial port is closed and opened
@@ -51,10 +114,14 @@ func main() {
 
 	log.Printf("Logfile %v", flags.Logfile)
 	var serialLog *log.Logger
+	var logFile *logrotate.Writer
 	if flags.Logfile {
 		log.Println("Create Serial Logger")
 		var closeSerialLogger func()
-		serialLog, closeSerialLogger = internal.StartSerialLogger(flags.Logfilepath)
+		logRotation, _ := flags.LogRotation() // checked by GetFlags
+		logFile, closeSerialLogger = internal.StartSerialLogger(flags.Logfilepath, logRotation,
+			internal.SerialLogHeader(msglog.LogFormat(flags.LogFormat), settings))
+		serialLog = log.New(logFile, "", 0)
 		if closeSerialLogger == nil {
 			log.Println("ERROR: closeSerialLogger is nil. Serial logger setup failed.")
 		} else {
@@ -63,5 +130,45 @@ func main() {
 		defer closeSerialLogger()
 	}
 
-	internal.RunTui(port, mode, flags, config, serialLog)
+	// share clients get the messages as text lines
+	var shareServer *share.Server
+	if len(shareListeners) > 0 {
+		shareServer, err = share.Start(shareListeners, settings.Port)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer shareServer.Close()
+	}
+
+	var pty *session.Pty
+	if flags.Pty {
+		pty, err = session.OpenPty(settings.Port)
+		if err != nil {
+			fmt.Println(err)
+			return 1
+		}
+		defer pty.Close()
+	}
+
+	if flags.Pipe {
+		if err := internal.RunPipe(port, settings, flags, serialLog, pty, shareServer); err != nil {
+			// stdout is the data of the port
+			fmt.Fprintln(os.Stderr, err)
+			return 1
+		}
+		return 0
+	}
+
+	internal.MigrateCmdHistory(flags.HistoryFile)
+	config.CmdHistoryLines = internal.LoadCmdHistory(flags.HistoryFile)
+	config.GlobalCmdHistoryLines = internal.LoadCmdHistory(internal.GlobalHistoryFile())
+	if err := internal.RunTui(port, settings, flags, config, logFile, runScript, pty, shareServer); err != nil {
+		fmt.Println("Script failed:", err)
+		return 1
+	}
+	if runScript != nil {
+		fmt.Println("Script succeeded")
+	}
+	return 0
 }